@@ -0,0 +1,116 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WithArgTypeCheck validates the arguments of prepared statements against
+// the parameter types the server infers for them, so type mismatches,
+// like a bool bound to an integer column, fail in tests, instead of in
+// production with stricter data. Only postgres 13 and newer report
+// parameter types, statements which can not be described are not checked.
+//
+// Strings, byte slices and nil are accepted for any parameter, since
+// postgres parses them according to the parameter type.
+func WithArgTypeCheck() Option {
+	return func(cfg *Config) error {
+		cfg.checkArgTypes = true
+		return nil
+	}
+}
+
+// paramTypes returns the parameter types postgres infers for query. The
+// statement is prepared within a savepoint, so a failure does not abort
+// the transaction.
+func (c *conn) paramTypes(ctx context.Context, tx *sql.Tx, query string) ([]string, error) {
+	// c must be locked before call
+	if c.SavePoint == nil {
+		return nil, nil
+	}
+	const name = "txdb_describe"
+	if err := c.execSavePoint(ctx, tx, c.SavePoint.Create(name)); err != nil {
+		return nil, err
+	}
+	types, err := describeParams(ctx, tx, name, query)
+	if err != nil {
+		// the types are unknown, but the savepoint must not stay behind
+		if err := c.execSavePoint(ctx, tx, c.SavePoint.Rollback(name)); err != nil {
+			return nil, err
+		}
+		types = nil
+	}
+	if err := c.execSavePoint(ctx, tx, c.SavePoint.Release(name)); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+func describeParams(ctx context.Context, tx *sql.Tx, name, query string) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, "PREPARE "+name+" AS "+query); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT p.t::text FROM pg_prepared_statements, unnest(parameter_types) WITH ORDINALITY AS p(t, n) WHERE name = $1 ORDER BY p.n", name)
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for rows.Next() {
+		var typ string
+		if err := rows.Scan(&typ); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		types = append(types, typ)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "DEALLOCATE "+name)
+	return types, err
+}
+
+// checkArgs validates args against the parameter types of s.
+func (s *stmt) checkArgs(args []interface{}) error {
+	for i, arg := range args {
+		if i >= len(s.paramTypes) {
+			break
+		}
+		if v, ok := arg.(sql.NamedArg); ok {
+			arg = v.Value
+		}
+		if !argMatches(arg, s.paramTypes[i]) {
+			return fmt.Errorf("txdb: argument $%d of statement %q is %T, which does not match parameter type %s, see WithArgTypeCheck", i+1, s.query, arg, s.paramTypes[i])
+		}
+	}
+	return nil
+}
+
+func argMatches(arg interface{}, typ string) bool {
+	if strings.HasSuffix(typ, "[]") || typ == "unknown" {
+		return true // arrays are bound as strings
+	}
+	switch arg.(type) {
+	case int64, int32, int, int16, int8, uint64, uint32, uint, uint16, uint8:
+		return isNumericType(typ)
+	case float64, float32:
+		return isNumericType(typ) && !strings.Contains(typ, "int")
+	case bool:
+		return typ == "boolean"
+	case time.Time:
+		return strings.HasPrefix(typ, "timestamp") || strings.HasPrefix(typ, "time") || typ == "date"
+	}
+	return true
+}
+
+func isNumericType(typ string) bool {
+	switch typ {
+	case "smallint", "integer", "bigint", "numeric", "real", "double precision", "money", "oid":
+		return true
+	}
+	return false
+}
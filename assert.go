@@ -0,0 +1,16 @@
+package txdb
+
+import "database/sql"
+
+// MustAffect fails the test immediately, unless result reports exactly n
+// affected rows.
+func MustAffect(t TB, result sql.Result, n int64) {
+	t.Helper()
+	affected, err := result.RowsAffected()
+	if err != nil {
+		t.Fatalf("txdb: failed to get affected rows: %s", err)
+	}
+	if affected != n {
+		t.Fatalf("txdb: expected %d affected rows, but got %d", n, affected)
+	}
+}
@@ -0,0 +1,44 @@
+package txdb
+
+import (
+	"context"
+	"regexp"
+)
+
+var (
+	scanPattern  = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(?:SELECT|WITH)\b.*\bFROM\b`)
+	limitPattern = regexp.MustCompile(`(?i)\b(?:LIMIT|FETCH\s+(?:FIRST|NEXT))\b`)
+)
+
+// WithUnboundedStreaming streams the rows of queries, which may return an
+// unbounded number of rows, like WithStreaming does, while small lookups
+// are still buffered. A query is considered unbounded if it is a SELECT
+// reading FROM a table without a LIMIT or FETCH FIRST clause, or if it
+// matches any of the given patterns.
+//
+// The caveats of WithStreaming apply, the rows of such queries must be
+// closed before another statement is issued on the same connection.
+func WithUnboundedStreaming(patterns ...*regexp.Regexp) Option {
+	return func(cfg *Config) error {
+		cfg.streamUnbounded = true
+		cfg.streamPatterns = append(cfg.streamPatterns, patterns...)
+		return nil
+	}
+}
+
+// unboundedStreaming returns ctx making the query stream its rows, if it
+// is unbounded, see WithUnboundedStreaming.
+func (c *conn) unboundedStreaming(ctx context.Context, query string) context.Context {
+	if !c.streamUnbounded || isStreaming(ctx) {
+		return ctx
+	}
+	for _, pattern := range c.streamPatterns {
+		if pattern.MatchString(query) {
+			return WithStreaming(ctx)
+		}
+	}
+	if scanPattern.MatchString(query) && !limitPattern.MatchString(query) {
+		return WithStreaming(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,87 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// WithBadConnDiagnostics makes errors, which render the connection
+// unusable, explain why. By default, when the wrapped driver reports
+// driver.ErrBadConn, database/sql silently discards the txdb connection,
+// together with its transaction, and retries on a new one, which only
+// makes tests slow or fail far from the root cause. With this option
+// such error is returned to the caller instead, and is also logged, see
+// WithLogger.
+func WithBadConnDiagnostics() Option {
+	return func(cfg *Config) error {
+		cfg.diagnoseBadConn = true
+		return nil
+	}
+}
+
+func (c *conn) diagnose(err error) error {
+	// c must be locked before call
+	c.noteLost(err)
+	if err == nil || !c.diagnoseBadConn {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, driver.ErrBadConn):
+		// must not wrap the error, database/sql would retry otherwise
+		err = withKind(fmt.Errorf("txdb: connection %q is broken, the wrapped driver reported: %v, the transaction is lost", c.dsn, err), ErrConnPoisoned)
+	case (errors.Is(err, sql.ErrTxDone) || errors.Is(err, context.Canceled)) && c.rootCanceled():
+		err = withKind(fmt.Errorf("txdb: transaction of connection %q was aborted, since the context of a statement was canceled while it was running: %w", c.dsn, err), ErrConnPoisoned)
+	default:
+		return err
+	}
+	c.logf("%s", err)
+	return err
+}
+
+// ResetSession implements driver.SessionResetter, it is called by
+// database/sql before a pooled connection is reused. If the root
+// transaction was aborted, since the context of a statement was canceled
+// while it was running, the transaction is discarded and driver.ErrBadConn
+// is reported, so database/sql does not reuse the connection, and the next
+// statement begins a new transaction. With WithBadConnDiagnostics, the
+// aborted transaction is kept instead, so the next statement explains it.
+func (c *conn) ResetSession(ctx context.Context) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.diagnoseBadConn || !c.aborted() {
+		return nil
+	}
+	c.logf("transaction aborted by a canceled context, discarding it")
+	c.rollbackRoot()
+	return driver.ErrBadConn
+}
+
+// IsValid implements driver.Validator, it is called by database/sql when
+// a connection is returned to the pool. It reports the connection invalid
+// once its root transaction was aborted, see ResetSession, so database/sql
+// closes it instead of reusing it, unless WithBadConnDiagnostics is set.
+func (c *conn) IsValid() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.diagnoseBadConn || !c.aborted()
+}
+
+// aborted reports whether the root transaction was aborted, since the
+// context of a statement was canceled while it was running.
+func (c *conn) aborted() bool {
+	// c must be locked before call
+	return c.tx != nil && c.lost == nil && c.reaped == nil && c.rootCanceled()
+}
+
+func (c *conn) rootCanceled() bool {
+	select {
+	case <-c.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
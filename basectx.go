@@ -0,0 +1,24 @@
+package txdb
+
+import "context"
+
+// WithBaseContext sets the context all internal operations of the
+// connection derive from, like beginning the root transaction, opening
+// the real database and the savepoints of Commit and Rollback, which
+// otherwise use context.Background(). It may carry a suite deadline or a
+// trace span. Once it is canceled, the root transaction is rolled back by
+// database/sql, and its connection is evicted from the pool, see IsValid.
+func WithBaseContext(ctx context.Context) Option {
+	return func(cfg *Config) error {
+		cfg.baseCtx = ctx
+		return nil
+	}
+}
+
+// base returns the context internal operations derive from.
+func (c *conn) base() context.Context {
+	if c.baseCtx == nil {
+		return context.Background()
+	}
+	return c.baseCtx
+}
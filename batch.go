@@ -0,0 +1,89 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// batchSavePoint is the savepoint id which makes a batch atomic.
+const batchSavePoint = "txdb_batch"
+
+// ExecBatch executes the given statements, usually fixtures, within the
+// transaction of the connection opened with the given dsn identifier.
+//
+// When the database accepts multiple statements at once, the whole batch
+// is sent in a single round trip. Otherwise, or when the batch fails,
+// statements are executed one by one, so the returned error points to the
+// failing statement. If save points are supported, the batch is atomic.
+func (d *TxDriver) ExecBatch(dsn string, stmts []string) error {
+	d.Lock()
+	c, ok := d.conns[dsn]
+	d.Unlock()
+	if !ok {
+		return fmt.Errorf("txdb: connection %q is not open", dsn)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	tx, err := c.beginOnce()
+	if err != nil {
+		return err
+	}
+
+	if c.SavePoint == nil {
+		return c.execEach(tx, stmts)
+	}
+
+	ctx := c.base()
+	if err := c.createPending(ctx); err != nil {
+		return err
+	}
+	if err := c.execSavePoint(ctx, tx, c.SavePoint.Create(batchSavePoint)); err != nil {
+		return err
+	}
+	if len(stmts) > 1 {
+		if _, err := tx.Exec(joinStatements(stmts)); err == nil {
+			for _, query := range stmts {
+				c.record(ctx, query, nil, nil)
+			}
+			return c.execSavePoint(ctx, tx, c.SavePoint.Release(batchSavePoint))
+		}
+		// the database may not support multiple statements, fall back
+		if err := c.execSavePoint(ctx, tx, c.SavePoint.Rollback(batchSavePoint)); err != nil {
+			return err
+		}
+	}
+	recorded := len(c.records)
+	if err := c.execEach(tx, stmts); err != nil {
+		if rerr := c.execSavePoint(ctx, tx, c.SavePoint.Rollback(batchSavePoint)); rerr != nil {
+			return rerr
+		}
+		c.rollbackRecords(recorded)
+		return err
+	}
+	return c.execSavePoint(ctx, tx, c.SavePoint.Release(batchSavePoint))
+}
+
+func (c *conn) execEach(tx *sql.Tx, stmts []string) error {
+	// c must be locked before call
+	for i, query := range stmts {
+		res, err := tx.Exec(query)
+		if err != nil {
+			return fmt.Errorf("txdb: batch statement %d failed: %w", i, err)
+		}
+		c.record(context.Background(), query, nil, res)
+	}
+	return nil
+}
+
+func joinStatements(stmts []string) string {
+	var b strings.Builder
+	for _, query := range stmts {
+		b.WriteString(strings.TrimRight(strings.TrimSpace(query), ";"))
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
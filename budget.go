@@ -0,0 +1,39 @@
+package txdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithTxBudget limits how long the root transaction of a connection may
+// stay open, measured from its first statement until the connection is
+// closed. Long running transactions hold locks and destabilize parallel
+// test suites sharing a database. When the budget is exceeded, onExceed
+// is called with the connection info and the duration the transaction
+// was open, or, if onExceed is nil, closing the connection returns an
+// error.
+func WithTxBudget(max time.Duration, onExceed func(info ConnInfo, open time.Duration)) Option {
+	return func(cfg *Config) error {
+		cfg.txBudget = max
+		cfg.onExceed = onExceed
+		return nil
+	}
+}
+
+// checkBudget returns either a call of the onExceed hook, or an error if
+// there is no hook, when the root transaction exceeded its budget.
+func (c *conn) checkBudget() (func(), error) {
+	// c must be locked before call
+	if c.txBudget <= 0 || c.tx == nil {
+		return nil, nil
+	}
+	open := time.Since(c.txStart)
+	if open <= c.txBudget {
+		return nil, nil
+	}
+	if c.onExceed == nil {
+		return nil, fmt.Errorf("txdb: transaction of connection %q was open for %s, exceeding the budget of %s", c.dsn, open, c.txBudget)
+	}
+	info, onExceed := c.info(), c.onExceed
+	return func() { onExceed(info, open) }, nil
+}
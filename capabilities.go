@@ -0,0 +1,102 @@
+package txdb
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// Capabilities describes features of the wrapped database driver and
+// backend, as detected by TxDriver.Capabilities.
+type Capabilities struct {
+	// SavePoints is set if savepoints can be created and released, which
+	// nested transactions rely on.
+	SavePoints bool
+	// MultiStatements is set if a single query may contain multiple
+	// statements, each returning its own result set.
+	MultiStatements bool
+	// NamedArgs is set if a statement accepts named arguments.
+	NamedArgs bool
+	// ColumnTypeDatabaseTypeName, ColumnTypeScanType and ColumnTypeNullable
+	// are set if the driver reports the respective column type information.
+	ColumnTypeDatabaseTypeName bool
+	ColumnTypeScanType         bool
+	ColumnTypeNullable         bool
+}
+
+// Capabilities probes the wrapped driver and the database behind it for
+// supported features, so test suites can skip or adapt tests based on
+// the actual backend. Every probe runs in its own transaction, which is
+// rolled back, on a separate connection to the real database.
+func (d *TxDriver) Capabilities() (Capabilities, error) {
+	var caps Capabilities
+	db, err := d.openReal()
+	if err != nil {
+		return caps, err
+	}
+	defer d.closeReal(db)
+
+	// fail early, if the database is not reachable at all
+	if err := db.Ping(); err != nil {
+		return caps, err
+	}
+
+	savePoint := &defaultSavePoint{}
+	caps.SavePoints = probe(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(savePoint.Create("txdb_probe")); err != nil {
+			return err
+		}
+		_, err := tx.Exec(savePoint.Release("txdb_probe"))
+		return err
+	})
+
+	caps.MultiStatements = probe(db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT 1; SELECT 2")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+		}
+		if !rows.NextResultSet() {
+			return sql.ErrNoRows
+		}
+		return rows.Err()
+	})
+
+	caps.NamedArgs = probe(db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT 1", sql.Named("txdb_probe", 1))
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+
+	probe(db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT 1")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		types, err := rows.ColumnTypes()
+		if err != nil || len(types) == 0 {
+			return err
+		}
+		caps.ColumnTypeDatabaseTypeName = types[0].DatabaseTypeName() != ""
+		caps.ColumnTypeScanType = types[0].ScanType() != reflect.TypeOf(new(interface{})).Elem()
+		_, caps.ColumnTypeNullable = types[0].Nullable()
+		return nil
+	})
+
+	return caps, nil
+}
+
+// probe reports whether f succeeds within a transaction, which is always
+// rolled back, since a failed statement may abort it on some databases.
+func probe(db *sql.DB, f func(tx *sql.Tx) error) bool {
+	tx, err := db.Begin()
+	if err != nil {
+		return false
+	}
+	defer tx.Rollback()
+	return f(tx) == nil
+}
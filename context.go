@@ -5,23 +5,22 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"io"
+	"time"
 )
 
-func buildRows(r *sql.Rows) (driver.Rows, error) {
-	set := &rowSets{}
-	rs := &rows{}
-	if err := rs.read(r); err != nil {
-		return set, err
-	}
-	set.sets = append(set.sets, rs)
-	for r.NextResultSet() {
-		rss := &rows{}
-		if err := rss.read(r); err != nil {
+func buildRows(c *conn, query string, r *sql.Rows) (*rowSets, error) {
+	set := &rowSets{guard: newRowsGuard(c)}
+	for {
+		rs := &rows{}
+		set.sets = append(set.sets, rs)
+		if err := rs.read(c, query, r); err != nil {
+			set.Close()
 			return set, err
 		}
-		set.sets = append(set.sets, rss)
+		if !r.NextResultSet() {
+			return set, nil
+		}
 	}
-	return set, nil
 }
 
 // Implement the "RowsNextResultSet" interface
@@ -40,15 +39,35 @@ func (rs *rowSets) NextResultSet() error {
 }
 
 func (c *conn) beginTxOnce(ctx context.Context, done <-chan struct{}) (*sql.Tx, error) {
+	if c.reaped != nil {
+		return nil, c.reaped
+	}
+	if c.lost != nil {
+		return nil, c.lostError()
+	}
 	if c.tx == nil {
-		rootCtx, cancel := context.WithCancel(context.Background())
-		tx, err := c.drv.db.BeginTx(rootCtx, &sql.TxOptions{})
+		start := time.Now()
+		rootCtx, cancel := context.WithCancel(c.base())
+		tx, err := c.beginRoot(rootCtx)
 		if err != nil {
 			cancel()
 			return nil, err
 		}
-		c.tx, c.ctx, c.cancel = tx, rootCtx, cancel
+		if err := c.initSession(tx); err != nil {
+			tx.Rollback()
+			c.releaseRoot()
+			cancel()
+			return nil, err
+		}
+		c.tx, c.ctx, c.cancel, c.txStart = tx, rootCtx, cancel, time.Now()
+		c.latency.Begin += time.Since(start)
+		c.logf("BEGIN")
+		c.emit(Event{Type: TxBegun})
 	}
+	if c.strictContext {
+		return c.tx, nil // the context cancels only the statement
+	}
+	rootCtx, cancel := c.ctx, c.cancel // the root transaction may end meanwhile
 	go func() {
 		select {
 		case <-ctx.Done():
@@ -57,42 +76,123 @@ func (c *conn) beginTxOnce(ctx context.Context, done <-chan struct{}) (*sql.Tx,
 				// the operation successfully finished at the "same time" as context cancellation, so we won't close ctx on tx
 			default:
 				// operation was interrupted by context cancel, so we cancel parent as well
-				c.cancel()
+				cancel()
 			}
 		case <-done:
 			// operation was successfully finished, so we don't close ctx on tx
-		case <-c.ctx.Done():
+		case <-rootCtx.Done():
 		}
 	}()
 	return c.tx, nil
 }
 
 // Implement the "QueryerContext" interface
-func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	begin, after := c.stmtHook("Query", query, args)
+	defer func() { after(err) }()
+
+	ctx, err = withHints(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	ctx = c.unboundedStreaming(ctx, query)
+
 	c.Lock()
 	defer c.Unlock()
+	begin()
+	defer func() { err = c.diagnose(err) }()
 
-	done := make(chan struct{})
-	defer close(done)
-
-	tx, err := c.beginTxOnce(ctx, done)
-	if err != nil {
+	if c.reaped != nil {
+		return nil, c.reaped
+	}
+	if err := c.beforeStatement(ctx, query); err != nil {
 		return nil, err
 	}
 
-	rs, err := tx.QueryContext(ctx, query, mapNamedArgs(args)...)
+	ctx, cancel := c.statementContext(ctx)
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+		err = c.statementError(ctx, query, err)
+	}()
+
+	margs := mapNamedArgs(args)
+	var rs *sql.Rows
+	if isDirect(ctx) {
+		var db *sql.DB
+		if db, err = c.realDB(); err == nil {
+			rs, err = db.QueryContext(ctx, query, margs...)
+		}
+	} else {
+		done := make(chan struct{})
+		defer close(done)
+
+		var tx *sql.Tx
+		if tx, err = c.beginTxOnce(ctx, done); err != nil {
+			return nil, err
+		}
+		if err = c.checkPlan(ctx, tx, query, margs); err != nil {
+			return nil, err
+		}
+		var end func(error) error
+		if end, err = c.statementSavePoint(ctx, tx, query); err != nil {
+			return nil, err
+		}
+		defer func() { err = end(err) }() // after the rows are read
+		rs, err = c.queryTx(ctx, tx, query, margs)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if isStreaming(ctx) {
+		c.record(ctx, query, margs, nil)
+		stop := cancel
+		cancel = nil // the rows are read after return
+		return newStreamRows(c, rs, stop)
+	}
 	defer rs.Close()
 
-	return buildRows(rs)
+	set, err := buildRows(c, query, rs)
+	c.recordRows(ctx, query, margs, set)
+	return set, err
 }
 
 // Implement the "ExecerContext" interface
-func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	begin, after := c.stmtHook("Exec", query, args)
+	defer func() { after(err) }()
+
+	ctx, err = withHints(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
 	c.Lock()
 	defer c.Unlock()
+	begin()
+	defer func() { err = c.diagnose(err) }()
+
+	if c.reaped != nil {
+		return nil, c.reaped
+	}
+	if err := c.beforeStatement(ctx, query); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	defer func() { err = c.statementError(ctx, query, err) }()
+
+	margs := mapNamedArgs(args)
+	if isDirect(ctx) {
+		db, err := c.realDB()
+		if err != nil {
+			return nil, err
+		}
+		res, err := db.ExecContext(ctx, query, margs...)
+		return c.result(res), err
+	}
 
 	done := make(chan struct{})
 	defer close(done)
@@ -101,19 +201,66 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 	if err != nil {
 		return nil, err
 	}
+	if err := c.checkPlan(ctx, tx, query, margs); err != nil {
+		return nil, err
+	}
+	end, err := c.statementSavePoint(ctx, tx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = end(err) }()
 
-	return tx.ExecContext(ctx, query, mapNamedArgs(args)...)
+	res, err := tx.ExecContext(ctx, query, margs...)
+	if err == nil {
+		c.record(ctx, query, margs, res)
+	}
+	return c.result(res), err
 }
 
 // Implement the "ConnBeginTx" interface
 func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	return c.Begin()
+	if err := c.checkContext(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.checkIsolation(sql.IsolationLevel(opts.Isolation)); err != nil {
+		return nil, err
+	}
+	if opts.ReadOnly && !c.enforceReadOnly {
+		if err := c.unsupported("read-only transaction is not enforced, see WithReadOnlyEnforcement"); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &tx{noSavePoint, c}
+	if !isSkipSavePoint(ctx) {
+		dt, err := c.begin(ctx, opts.ReadOnly)
+		if err != nil {
+			return nil, err
+		}
+		t = dt.(*tx)
+	}
+	c.recordBegin(ctx, opts)
+	return t, nil
 }
 
 // Implement the "ConnPrepareContext" interface
-func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+func (c *conn) PrepareContext(ctx context.Context, query string) (_ driver.Stmt, err error) {
+	begin, after := c.stmtHook("Prepare", query, nil)
+	defer func() { after(err) }()
+
+	ctx, err = withHints(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
 	c.Lock()
 	defer c.Unlock()
+	begin()
+	defer func() { err = c.diagnose(err) }()
+
+	if err := c.checkContext(ctx); err != nil {
+		return nil, err
+	}
 
 	done := make(chan struct{})
 	defer close(done)
@@ -123,46 +270,122 @@ func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, e
 		return nil, err
 	}
 
-	st, err := tx.PrepareContext(ctx, query)
+	var st *sql.Stmt
+	if isDirect(ctx) {
+		var db *sql.DB
+		if db, err = c.realDB(); err == nil {
+			st, err = db.PrepareContext(ctx, query)
+		}
+	} else {
+		st, err = tx.PrepareContext(ctx, query)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	stmtFailedStr := make(chan bool)
-	go func() {
-		select {
-		case <-c.ctx.Done():
-		case erred := <-stmtFailedStr:
-			if erred {
-				st.Close()
-			}
-		}
-	}()
-	return &stmt{st: st, done: stmtFailedStr}, nil
+	s := &stmt{st: st, conn: c, query: query}
+	if isDirect(ctx) {
+		return s, nil // not part of the transaction
+	}
+	if c.checkArgTypes {
+		s.paramTypes, _ = c.paramTypes(ctx, tx, query)
+	}
+	return c.track(s), nil
 }
 
 // Implement the "Pinger" interface
 func (c *conn) Ping(ctx context.Context) error {
-	return c.drv.db.PingContext(ctx)
+	c.drv.Lock()
+	db, err := c.realDB()
+	c.drv.Unlock()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
 }
 
 // Implement the "StmtExecContext" interface
-func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	dr, err := s.st.ExecContext(ctx, mapNamedArgs(args)...)
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	begin, after := s.conn.stmtHook("Exec", s.query, args)
+	defer func() { after(err) }()
+
+	ctx, err = withHints(ctx, s.query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.conn.Lock()
+	defer s.conn.Unlock()
+	begin()
+	defer func() { err = s.conn.diagnose(err) }()
+
+	if err := s.beforeStatement(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.conn.statementContext(ctx)
+	defer cancel()
+	defer func() { err = s.conn.statementError(ctx, s.query, err) }()
+
+	margs := mapNamedArgs(args)
+	if err := s.checkArgs(margs); err != nil {
+		return nil, err
+	}
+	dr, err := s.st.ExecContext(ctx, margs...)
 	if err != nil {
-		s.closeDone(true)
+		return dr, err
 	}
-	return dr, err
+	s.conn.record(ctx, s.query, margs, dr)
+	return s.conn.result(dr), nil
 }
 
 // Implement the "StmtQueryContext" interface
-func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	rows, err := s.st.QueryContext(ctx, mapNamedArgs(args)...)
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	begin, after := s.conn.stmtHook("Query", s.query, args)
+	defer func() { after(err) }()
+
+	ctx, err = withHints(ctx, s.query)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.conn.unboundedStreaming(ctx, s.query)
+
+	s.conn.Lock()
+	defer s.conn.Unlock()
+	begin()
+	defer func() { err = s.conn.diagnose(err) }()
+
+	if err := s.beforeStatement(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.conn.statementContext(ctx)
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+		err = s.conn.statementError(ctx, s.query, err)
+	}()
+
+	margs := mapNamedArgs(args)
+	if err := s.checkArgs(margs); err != nil {
+		return nil, err
+	}
+	rows, err := s.st.QueryContext(ctx, margs...)
 	if err != nil {
-		s.closeDone(true)
 		return nil, err
 	}
-	return buildRows(rows)
+	if isStreaming(ctx) {
+		s.conn.record(ctx, s.query, margs, nil)
+		stop := cancel
+		cancel = nil // the rows are read after return
+		return newStreamRows(s.conn, rows, stop)
+	}
+	defer rows.Close()
+
+	set, err := buildRows(s.conn, s.query, rows)
+	s.conn.recordRows(ctx, s.query, margs, set)
+	return set, err
 }
 
 func mapNamedArgs(args []driver.NamedValue) (res []interface{}) {
@@ -177,3 +400,50 @@ func mapNamedArgs(args []driver.NamedValue) (res []interface{}) {
 	}
 	return
 }
+
+type ctxKey int
+
+const (
+	streamingKey ctxKey = iota
+	skipSavePointKey
+	noRecordKey
+	directKey
+	timeoutKey
+)
+
+// WithStreaming returns a context which makes queries issued with it
+// stream rows directly from the underlying driver, instead of buffering
+// the whole result in memory. The connection is not locked while rows
+// are being read, so the rows must be closed before another statement is
+// issued on the same connection. Only the first result set is available.
+func WithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey, true)
+}
+
+// SkipSavePoint returns a context which makes transactions begun with it
+// run without a savepoint. Commit and Rollback of such transaction are
+// no-ops, so all changes made within it stay in the root transaction.
+func SkipSavePoint(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipSavePointKey, true)
+}
+
+// NoRecord returns a context which excludes statements issued with it
+// from recording, see WithRecording.
+func NoRecord(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRecordKey, true)
+}
+
+func isStreaming(ctx context.Context) bool {
+	v, _ := ctx.Value(streamingKey).(bool)
+	return v
+}
+
+func isSkipSavePoint(ctx context.Context) bool {
+	v, _ := ctx.Value(skipSavePointKey).(bool)
+	return v
+}
+
+func isNoRecord(ctx context.Context) bool {
+	v, _ := ctx.Value(noRecordKey).(bool)
+	return v
+}
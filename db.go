@@ -49,6 +49,28 @@ username:
 	}
 
 Every time you will run this application, it will remain in the same state as before.
+
+All connections opened with the same dsn identifier share a single transaction,
+including connections pinned with [database/sql.DB.Conn]. Session scoped
+features, like SET LOCAL or temporary tables, are therefore visible through the
+[database/sql.DB] and every [database/sql.Conn] taken from it, and transactions
+begun on any of them share one stack of savepoints. They nest to any depth,
+committing or rolling back a transaction ends the ones begun within it as well.
+
+Behavior of a single statement can be adjusted with a context, see
+[WithStreaming], [SkipSavePoint] and [NoRecord], or, for code which can not
+pass a context, with a txdb comment hint in the statement text. It is a
+block comment whose text starts with "txdb:" followed by comma separated
+hints, for example "txdb:stream,norecord".
+
+Supported hints are "stream", "norecord" and "direct". The latter executes
+the statement on the real database outside of the transaction, so its
+changes are not rolled back.
+
+Options of a single connection can be overridden with query parameters of
+the dsn identifier, for example "mytest?savepoint=off&isolation=serializable".
+Supported parameters are "savepoint", "isolation" and "timeout", see
+[SavePointOption], [WithIsolation] and [WithStatementTimeout].
 */
 package txdb
 
@@ -56,24 +78,25 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // New returns a [database/sql/driver.Connector], which can be passed to
 // [database/sql.OpenDB]. This can be used in place of [Register].
 // It takes the same arguments as [Register], with the omission of name.
-func New(drv, dsn string, options ...func(*conn) error) driver.Connector {
+func New(drv, dsn string, options ...Option) driver.Connector {
+	registerMu.Lock()
+	defer registerMu.Unlock()
 	return &txConnector{
-		driver: &TxDriver{
-			dsn:     dsn,
-			drv:     drv,
-			conns:   make(map[string]*conn),
-			options: options,
-		},
-		name: "connector",
+		driver: newDriver(drv, dsn, options),
+		name:   "connector",
 	}
 }
 
@@ -96,23 +119,86 @@ func New(drv, dsn string, options ...func(*conn) error) driver.Connector {
 // Note: if you open a secondary database, make sure to differentiate
 // the dsn string when opening the [driver/sql.DB]. The transaction will be
 // isolated within that dsn.
-func Register(name, drv, dsn string, options ...func(*conn) error) {
-	sql.Register(name, &TxDriver{
+//
+// The registered driver is returned, so its state can be inspected and
+// controlled, e.g. in the teardown of a test suite.
+func Register(name, drv, dsn string, options ...Option) *TxDriver {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	return register(name, newDriver(drv, dsn, options))
+}
+
+func newDriver(drv, dsn string, options []Option) *TxDriver {
+	// registerMu must be locked before call
+	d := &TxDriver{
 		dsn:     dsn,
 		drv:     drv,
 		conns:   make(map[string]*conn),
 		options: options,
-	})
+	}
+	if inner, ok := registeredDriver(drv); ok {
+		d.layer = layerOf(inner)
+	}
+	if err := d.validate(); err != nil {
+		d.err = d.invalid(err)
+	}
+	return d
 }
 
 type conn struct {
-	sync.Mutex
-	tx        *sql.Tx
-	dsn       string
-	opened    uint
-	drv       *TxDriver
-	saves     uint
-	savePoint SavePoint
+	connLock
+	Config
+	tx       *sql.Tx
+	opened   uint
+	drv      *TxDriver
+	saves    uint
+	discards uint // savepoints up to this one were discarded by TxDriver.Rollback or Reset
+
+	pending []string // ids of savepoints not created yet, outermost first
+
+	lost error // set once the root transaction is lost
+
+	savePointSeqs map[string]uint // savepoint names to their sequence numbers
+
+	rootConn *sql.Conn // pinned by the root transaction, see RawConn
+
+	stmtSaves uint
+
+	queryStmts   map[string]*sql.Stmt // prepared queries of queryStmtsTx
+	queryStmtsTx *sql.Tx
+
+	generation atomic.Uint64 // of the root transaction
+
+	latency   Latency      // except statements, which are timed atomically
+	stmtNanos atomic.Int64 // without the connection locked
+	stmtCount atomic.Int64
+
+	txBegan map[string]time.Time // begin times of nested transactions by savepoint id
+
+	prepared map[*stmt]struct{} // open statements prepared within the transaction
+
+	stack    []string        // ids of open savepoints, outermost first
+	ended    map[string]bool // savepoints ended with an enclosing one, to whether it was rolled back
+	maxDepth int             // high-water mark of the stack depth
+
+	records []Record
+	marks   map[string]int // savepoint id to the number of records when created
+
+	txStart   time.Time // when the root transaction has begun
+	created   time.Time
+	lastUsed  atomic.Int64 // unix nanoseconds, see touch
+	lastQuery string
+
+	reaped error // set once the connection is reaped
+
+	tempDir string // see TempDir
+
+	statsBefore map[string]QueryStat
+	statsErr    error
+
+	sqlModeBefore string
+
+	readOnly string // id of the outermost read-only savepoint
 
 	cancel func()
 	ctx    interface{ Done() <-chan struct{} }
@@ -123,13 +209,27 @@ type conn struct {
 // rolled back.
 type TxDriver struct {
 	sync.Mutex
-	db       *sql.DB
-	realConn driver.Conn // Meant to be used as NamedValueChecker
-	conns    map[string]*conn
-	options  []func(*conn) error
-
-	drv string
-	dsn string
+	db        *sql.DB
+	pool      *sql.DB          // pool given to RegisterDB, owned by the caller
+	connector driver.Connector // connector given to RegisterConnector
+	realConn  driver.Conn      // Meant to be used as NamedValueChecker
+	conns     map[string]*conn
+	options   []Option
+	aliases   map[string]string // replica dsn identifiers to the primary one
+
+	stopReaper    func()
+	bootstrapped  bool
+	persistent    bool          // see WithPersistentDB
+	version       ServerVersion // see serverVersion
+	versionProbed bool
+	closeErrs     map[string]error   // outcome of the last final close by dsn
+	latencies     map[string]Latency // of the last final close by dsn
+
+	name  string // registered with, empty for New
+	drv   string
+	dsn   string
+	layer int   // number of txdb drivers wrapped, see layerOf
+	err   error // registration error, returned by every Open
 }
 
 var (
@@ -172,97 +272,310 @@ func (d *TxDriver) OpenConnector(name string) (driver.Connector, error) {
 func (d *TxDriver) Open(dsn string) (driver.Conn, error) {
 	d.Lock()
 	defer d.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if primary, ok := d.aliases[dsn]; ok {
+		dsn = primary
+	}
+	c, ok := d.conns[dsn]
+	if !ok {
+		c = &conn{
+			Config:  Config{dsn: dsn, SavePoint: &defaultSavePoint{}, allowedDSN: defaultAllowedDSNPattern},
+			drv:     d,
+			created: time.Now(),
+			cancel:  func() {},
+			ctx:     stubCtx{},
+		}
+		for _, opt := range d.options {
+			if e := opt(&c.Config); e != nil {
+				return c, e
+			}
+		}
+		for _, opt := range c.dsnOptions {
+			if e := opt(&c.Config); e != nil {
+				return c, e
+			}
+		}
+		params, err := dsnParamOptions(dsn, c.SavePoint)
+		if err != nil {
+			return c, err
+		}
+		for _, opt := range params {
+			if e := opt(&c.Config); e != nil {
+				return c, e
+			}
+		}
+		c.fair = c.fairLocking
+		if err := c.checkOptions(); err != nil {
+			return c, err
+		}
+	}
 	// first open a real database connection
 	if d.db == nil {
-		db, err := sql.Open(d.drv, d.dsn)
+		if d.pool == nil && d.connector == nil && d.layer == 0 && d.drv != memoryDriver && c.allowedDSN != nil && !c.allowedDSN.MatchString(dsnDatabase(d.drv, d.dsn)) {
+			return nil, fmt.Errorf("txdb: refusing to open %s database %q, its name does not match the allowed pattern %q, see WithAllowedDSNPattern", d.drv, dsnDatabase(d.drv, d.dsn), c.allowedDSN)
+		}
+		// drivers implementing driver.DriverContext parse the dsn here
+		db, err := d.openReal()
 		if err != nil {
-			return nil, err
+			return nil, d.invalid(err)
+		}
+		if db != d.pool {
+			for _, tune := range c.tunePool {
+				tune(db)
+			}
+		}
+		if !d.bootstrapped && (c.bootstrap != nil || len(c.fixtures) > 0) {
+			if c.bootstrap != nil {
+				if err := c.bootstrap(db); err != nil {
+					d.closeReal(db)
+					return nil, fmt.Errorf("txdb: bootstrap failed: %w", err)
+				}
+			}
+			for _, f := range c.fixtures {
+				if err := f.load(db); err != nil {
+					d.closeReal(db)
+					return nil, err
+				}
+			}
+			d.bootstrapped = true
 		}
 		d.db = db
 
-		realConn, err := db.Driver().Open(d.dsn)
-		if err != nil {
+		if err := c.retry(c.base(), "OPEN", func() error {
+			_, err := d.describer()
+			return err
+		}); err != nil {
+			d.db = nil
+			d.closeReal(db)
 			return nil, err
 		}
-		d.realConn = realConn
+		if c.reapIdle > 0 {
+			d.startReaper(c.reapIdle)
+		}
+		d.persistent = c.persistentDB
 	}
-	c, ok := d.conns[dsn]
 	if !ok {
-		c = &conn{
-			dsn:       dsn,
-			drv:       d,
-			savePoint: &defaultSavePoint{},
-			cancel:    func() {},
-			ctx:       stubCtx{},
-		}
-		for _, opt := range d.options {
-			if e := opt(c); e != nil {
-				return c, e
-			}
+		if c.reportStats != nil {
+			c.statsBefore, c.statsErr = d.queryStats()
 		}
 		d.conns[dsn] = c
 	}
-	c.opened++ // safe since conn.Close() must acquire driver lock first
+	c.Lock()
+	c.opened++ // conn.Close() must acquire driver lock first, statements don't
+	c.touch()
+	c.Unlock()
+	c.emit(Event{Type: ConnOpened})
 	return c, nil
 }
 
+func (d *TxDriver) numInput(query string) int {
+	d.Lock()
+	defer d.Unlock()
+	if d.db == nil {
+		return -1
+	}
+	realConn, err := d.describer()
+	if err != nil || realConn == nil {
+		return -1
+	}
+	st, err := realConn.Prepare(query)
+	if err != nil {
+		d.dropDescriber(err)
+		return -1 // e.g. refers to a table created within the transaction
+	}
+	defer st.Close()
+	return st.NumInput()
+}
+
 func (d *TxDriver) deleteConn(dsn string) error {
 	// d must be locked before call
 	delete(d.conns, dsn)
-	if len(d.conns) == 0 && d.db != nil {
-		if err := d.db.Close(); err != nil {
+	if len(d.conns) == 0 && !d.persistent {
+		return d.closeDB()
+	}
+	return nil
+}
+
+// closeDB closes the real database, if open.
+func (d *TxDriver) closeDB() error {
+	// d must be locked before call
+	if d.db != nil {
+		if d.stopReaper != nil {
+			d.stopReaper()
+			d.stopReaper = nil
+		}
+		if err := d.closeReal(d.db); err != nil {
 			return err
 		}
 		d.db = nil
-		if err := d.realConn.Close(); err != nil {
-			return err
+		if d.pool == nil && d.drv == memoryDriver {
+			// the in-memory database is freed with its last connection
+			d.bootstrapped = false
+		}
+		if d.realConn != nil {
+			realConn := d.realConn
+			d.realConn = nil
+			if err := realConn.Close(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
 func (c *conn) beginOnce() (*sql.Tx, error) {
+	if c.reaped != nil {
+		return nil, c.reaped
+	}
+	if c.lost != nil {
+		return nil, c.lostError()
+	}
 	if c.tx == nil {
-		tx, err := c.drv.db.Begin()
+		start := time.Now()
+		tx, err := c.beginRoot(c.base())
 		if err != nil {
 			return nil, err
 		}
-		c.tx = tx
+		if err := c.initSession(tx); err != nil {
+			tx.Rollback()
+			c.releaseRoot()
+			return nil, err
+		}
+		c.tx, c.txStart = tx, time.Now()
+		c.latency.Begin += time.Since(start)
+		c.logf("BEGIN")
+		c.emit(Event{Type: TxBegun})
 	}
 	return c.tx, nil
 }
 
+// realDB returns the real database of the driver, or the error the
+// connection fails with, once it was reaped or its driver was closed.
+func (c *conn) realDB() (*sql.DB, error) {
+	// c or its driver must be locked before call
+	if c.reaped != nil {
+		return nil, c.reaped
+	}
+	if c.drv.db == nil {
+		return nil, withKind(fmt.Errorf("txdb: real database of connection %q is closed", c.dsn), ErrConnClosed)
+	}
+	return c.drv.db, nil
+}
+
 func (c *conn) Close() (err error) {
+	var hooks []func()
+	defer func() {
+		for _, hook := range hooks {
+			hook() // called without the driver lock held
+		}
+	}()
+
 	c.drv.Lock()
 	defer c.drv.Unlock()
 
+	c.Lock()
 	c.opened--
+	c.Unlock()
+	c.emit(Event{Type: ConnClosed})
 	if c.opened == 0 {
+		if c.reaped != nil {
+			return nil // already rolled back and removed by the reaper
+		}
+		defer func() {
+			c.Lock()
+			latency := c.currentLatency()
+			c.Unlock()
+			c.drv.setCloseError(c.dsn, err)
+			c.drv.setLatency(c.dsn, latency)
+		}()
+		defer func() {
+			if cleanupErr := c.cleanup(); err == nil {
+				err = cleanupErr
+			}
+		}()
+		if c.reportStats != nil {
+			hooks = append(hooks, c.queryStatsReport())
+		}
+		var budgetErr, teardownErr error
+		c.expireRows()
+		if c.lost != nil || c.aborted() {
+			c.cancel()
+			c.tx = nil // nothing to roll back
+			c.releaseRoot()
+		}
 		if c.tx != nil {
+			var exceeded func()
+			if exceeded, budgetErr = c.checkBudget(); exceeded != nil {
+				hooks = append(hooks, exceeded)
+			}
+			start := time.Now()
+			teardownErr = c.teardown()
+			c.resetSession()
+			c.logf("ROLLBACK")
 			err := c.tx.Rollback()
+			c.Lock()
+			c.latency.Rollback += time.Since(start)
+			c.Unlock()
+			if err != nil {
+				err = fmt.Errorf("txdb: failed to rollback %q: %w", c.dsn, err)
+			}
+			if err != nil && c.restoreOnFailure {
+				err = c.restore(err)
+				c.cancel()
+				c.tx = nil
+				c.releaseRoot()
+				c.drv.deleteConn(c.dsn)
+			}
 			if err != nil {
 				return err
 			}
 			c.cancel()
 			c.tx = nil
+			c.releaseRoot()
 		}
-		return c.drv.deleteConn(c.dsn)
+		if err := c.drv.deleteConn(c.dsn); err != nil {
+			return err
+		}
+		if teardownErr != nil {
+			return teardownErr
+		}
+		return budgetErr
 	}
 	return
 }
 
+// noSavePoint is the id of a nested transaction without a savepoint,
+// its Commit and Rollback are no-ops.
+const noSavePoint = "_"
+
 type tx struct {
 	id   string
 	conn *conn
 }
 
 func (c *conn) Begin() (driver.Tx, error) {
-	if c.savePoint == nil {
-		return &tx{"_", c}, nil // save point is not supported
+	return c.begin(c.base(), false)
+}
+
+// begin starts a nested transaction, the savepoint is created with ctx.
+// If readOnly, it rejects writes, see WithReadOnlyEnforcement.
+func (c *conn) begin(ctx context.Context, readOnly bool) (_ driver.Tx, err error) {
+	if c.SavePoint == nil {
+		if err := c.unsupported("nested transaction can not be rolled back without savepoints"); err != nil {
+			return nil, withKind(err, ErrSavepointUnsupported)
+		}
+		return &tx{disabledSavePoint, c}, nil // save point is not supported
 	}
 
 	c.Lock()
 	defer c.Unlock()
+	defer func() { err = c.diagnose(err) }()
+
+	if c.depthCap > 0 && len(c.stack) >= c.depthCap {
+		return nil, withKind(fmt.Errorf("txdb: savepoint depth limit of %d reached on %q, a nested transaction is probably never committed or rolled back", c.depthCap, c.dsn), ErrSavepointDepth)
+	}
 
 	connTx, err := c.beginOnce()
 	if err != nil {
@@ -270,51 +583,142 @@ func (c *conn) Begin() (driver.Tx, error) {
 	}
 
 	c.saves++
-	id := fmt.Sprintf("tx_%d", c.saves)
-	_, err = connTx.Exec(c.savePoint.Create(id))
+	id, err := c.savePointID(c.saves)
 	if err != nil {
 		return nil, err
 	}
+	if c.lazySavePoints {
+		c.pending = append(c.pending, id)
+	} else {
+		if err := c.execSavePoint(ctx, connTx, c.SavePoint.Create(id)); err != nil {
+			return nil, err
+		}
+		c.emit(Event{Type: SavePointCreated, SavePoint: id})
+	}
+	c.markSavePoint(id)
+	c.stack = append(c.stack, id)
+	if len(c.stack) > c.maxDepth {
+		c.maxDepth = len(c.stack)
+	}
+	if readOnly {
+		c.enterReadOnly(id) // before any other statement gets the lock
+	}
+	c.txBegun(id)
 	return &tx{id, c}, nil
 }
 
-func (tx *tx) Commit() error {
-	if tx.conn.savePoint == nil {
-		return nil // save point is not supported
+// execSavePoint executes a savepoint statement with ctx, bounded by the
+// timeout set with WithSavePointTimeout. Commit and Rollback use a
+// background context, since the context a transaction was begun with
+// is often canceled by then.
+func (c *conn) execSavePoint(ctx context.Context, tx *sql.Tx, query string) error {
+	// c must be locked before call
+	c.logf("%s", query)
+	defer func(start time.Time) { c.latency.SavePoints += time.Since(start) }(time.Now())
+	if c.SavePointTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.SavePointTimeout)
+		defer cancel()
+	}
+	_, err := tx.ExecContext(ctx, query)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("txdb: savepoint statement %q did not finish within %s: %w", query, c.SavePointTimeout, err)
+	}
+	if err != nil {
+		return fmt.Errorf("txdb: savepoint statement %q failed: %w", query, err)
+	}
+	return nil
+}
+
+func (tx *tx) Commit() (err error) {
+	switch tx.id {
+	case disabledSavePoint:
+		return tx.conn.savePointless("Commit")
+	case noSavePoint:
+		return nil // save point was skipped
 	}
 
 	tx.conn.Lock()
 	defer tx.conn.Unlock()
+	if tx.conn.discarded(tx.id) {
+		return withKind(fmt.Errorf("txdb: nested transaction %s was discarded, since the transaction of %q was rolled back or reset", tx.id, tx.conn.dsn), ErrTxClosed)
+	}
+	level := slices.Index(tx.conn.stack, tx.id)
+	if level < 0 {
+		return tx.conn.endedError(tx.id)
+	}
+	defer func() { tx.conn.txEnded(tx.conn.txHooks.Commit, tx.id, err) }()
+	defer func() { err = tx.conn.diagnose(err) }()
+	defer tx.conn.leaveSavePoint(level, false)
+	defer tx.conn.leaveReadOnly(tx.id)
 
 	connTx, err := tx.conn.beginOnce()
 	if err != nil {
 		return err
 	}
 
-	_, err = connTx.Exec(tx.conn.savePoint.Release(tx.id))
+	if tx.conn.endPending(tx.id) {
+		delete(tx.conn.marks, tx.id)
+		return nil
+	}
+	err = tx.conn.execSavePoint(tx.conn.base(), connTx, tx.conn.SavePoint.Release(tx.id))
+	if err == nil {
+		tx.conn.emit(Event{Type: SavePointReleased, SavePoint: tx.id})
+	}
+	delete(tx.conn.marks, tx.id)
 	return err
 }
 
-func (tx *tx) Rollback() error {
-	if tx.conn.savePoint == nil {
-		return nil // save point is not supported
+func (tx *tx) Rollback() (err error) {
+	switch tx.id {
+	case disabledSavePoint:
+		return tx.conn.savePointless("Rollback")
+	case noSavePoint:
+		return nil // save point was skipped
 	}
 
 	tx.conn.Lock()
 	defer tx.conn.Unlock()
+	if tx.conn.discarded(tx.id) {
+		return nil // already rolled back with the root transaction
+	}
+	level := slices.Index(tx.conn.stack, tx.id)
+	if level < 0 {
+		if tx.conn.ended[tx.id] {
+			return nil // already rolled back with an enclosing transaction
+		}
+		return tx.conn.endedError(tx.id)
+	}
+	defer func() { tx.conn.txEnded(tx.conn.txHooks.Rollback, tx.id, err) }()
+	defer func() { err = tx.conn.diagnose(err) }()
+	defer tx.conn.leaveSavePoint(level, true)
+	defer tx.conn.leaveReadOnly(tx.id)
 
 	connTx, err := tx.conn.beginOnce()
 	if err != nil {
 		return err
 	}
 
-	_, err = connTx.Exec(tx.conn.savePoint.Rollback(tx.id))
+	if tx.conn.endPending(tx.id) {
+		err = nil // nothing was written since
+	} else {
+		err = tx.conn.execSavePoint(tx.conn.base(), connTx, tx.conn.SavePoint.Rollback(tx.id))
+		if err == nil {
+			tx.conn.emit(Event{Type: SavePointRolledBack, SavePoint: tx.id})
+		}
+	}
+	if err == nil {
+		tx.conn.rollbackRecords(tx.conn.marks[tx.id])
+		tx.conn.closePrepared(tx.id)
+	}
+	delete(tx.conn.marks, tx.id)
 	return err
 }
 
-func (c *conn) Prepare(query string) (driver.Stmt, error) {
+func (c *conn) Prepare(query string) (_ driver.Stmt, err error) {
 	c.Lock()
 	defer c.Unlock()
+	defer func() { err = c.diagnose(err) }()
 
 	tx, err := c.beginOnce()
 	if err != nil {
@@ -325,19 +729,29 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &stmt{st: st}, nil
+	return c.track(&stmt{st: st, conn: c, query: query}), nil
 }
 
-func (c *conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+func (c *conn) Exec(query string, args []driver.Value) (_ driver.Result, err error) {
 	c.Lock()
 	defer c.Unlock()
+	defer func() { err = c.diagnose(err) }()
+
+	if err := c.beforeStatement(c.base(), query); err != nil {
+		return nil, err
+	}
 
 	tx, err := c.beginOnce()
 	if err != nil {
 		return nil, err
 	}
 
-	return tx.Exec(query, mapArgs(args)...)
+	margs := mapArgs(args)
+	res, err := tx.Exec(query, margs...)
+	if err == nil {
+		c.record(context.Background(), query, margs, res)
+	}
+	return c.result(res), err
 }
 
 func mapArgs(args []driver.Value) (res []interface{}) {
@@ -348,9 +762,14 @@ func mapArgs(args []driver.Value) (res []interface{}) {
 	return
 }
 
-func (c *conn) Query(query string, args []driver.Value) (driver.Rows, error) {
+func (c *conn) Query(query string, args []driver.Value) (_ driver.Rows, err error) {
 	c.Lock()
 	defer c.Unlock()
+	defer func() { err = c.diagnose(err) }()
+
+	if err := c.beforeStatement(c.base(), query); err != nil {
+		return nil, err
+	}
 
 	tx, err := c.beginOnce()
 	if err != nil {
@@ -358,18 +777,27 @@ func (c *conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 	}
 
 	// query rows
-	rs, err := tx.Query(query, mapArgs(args)...)
+	margs := mapArgs(args)
+	rs, err := c.queryTx(c.base(), tx, query, margs)
 	if err != nil {
 		return nil, err
 	}
 	defer rs.Close()
 
-	return buildRows(rs)
+	set, err := buildRows(c, query, rs)
+	c.recordRows(context.Background(), query, margs, set)
+	return set, err
 }
 
 // Implement the NamedValueChecker interface
 func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
-	if nvc, ok := c.drv.realConn.(driver.NamedValueChecker); ok {
+	c.drv.Lock()
+	var realConn driver.Conn
+	if c.drv.db != nil {
+		realConn, _ = c.drv.describer()
+	}
+	c.drv.Unlock()
+	if nvc, ok := realConn.(driver.NamedValueChecker); ok {
 		return nvc.CheckNamedValue(nv)
 	}
 
@@ -381,56 +809,81 @@ func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
 	}
 }
 
+// stmt is a statement prepared within the transaction. Like any other
+// statement of the connection, it is executed with the connection locked,
+// so it may be used by several goroutines at once.
 type stmt struct {
-	mu   sync.Mutex
-	st   *sql.Stmt
-	done chan bool
+	st    *sql.Stmt
+	conn  *conn
+	query string
+
+	describe sync.Once
+	numInput int
+
+	paramTypes []string // see WithArgTypeCheck
+
+	saves    uint   // savepoints created before it was prepared
+	closedBy string // savepoint or transaction whose rollback closed it
 }
 
-func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
-	dr, err := s.st.Exec(mapArgs(args)...)
+func (s *stmt) Exec(args []driver.Value) (_ driver.Result, err error) {
+	s.conn.Lock()
+	defer s.conn.Unlock()
+	defer func() { err = s.conn.diagnose(err) }()
+
+	if err := s.beforeStatement(s.conn.base()); err != nil {
+		return nil, err
+	}
+
+	margs := mapArgs(args)
+	dr, err := s.st.Exec(margs...)
 	if err != nil {
-		s.closeDone(true)
+		return dr, err
 	}
-	return dr, err
+	s.conn.record(context.Background(), s.query, margs, dr)
+	return s.conn.result(dr), nil
 }
 
+// NumInput returns the number of placeholder parameters, as reported by
+// the wrapped driver, or -1 if it is unknown. The *sql.Stmt used within
+// the transaction hides it, so the statement is described by preparing
+// it once more, outside of the transaction.
 func (s *stmt) NumInput() int {
-	return -1
+	s.describe.Do(func() {
+		s.numInput = s.conn.drv.numInput(s.query)
+	})
+	return s.numInput
 }
 
 func (s *stmt) Close() error {
-	s.closeDone(false)
+	s.conn.untrack(s)
 	return s.st.Close()
 }
 
-func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
-	rows, err := s.st.Query(mapArgs(args)...)
-	if err != nil {
-		s.closeDone(true)
-		return nil, err
-	}
-	return buildRows(rows)
-}
+func (s *stmt) Query(args []driver.Value) (_ driver.Rows, err error) {
+	s.conn.Lock()
+	defer s.conn.Unlock()
+	defer func() { err = s.conn.diagnose(err) }()
 
-func (s *stmt) closeDone(withErr bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.done == nil {
-		return
+	if err := s.beforeStatement(s.conn.base()); err != nil {
+		return nil, err
 	}
 
-	select {
-	case s.done <- withErr:
-	default:
+	margs := mapArgs(args)
+	rows, err := s.st.Query(margs...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	close(s.done)
-	s.done = nil
+	set, err := buildRows(s.conn, s.query, rows)
+	s.conn.recordRows(context.Background(), s.query, margs, set)
+	return set, err
 }
 
 type rows struct {
-	rows     [][]driver.Value
+	store    RowStore
+	len      int
 	pos      int
 	cols     []string
 	colTypes []*sql.ColumnType
@@ -446,22 +899,26 @@ func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
 
 func (r *rows) Next(dest []driver.Value) error {
 	r.pos++
-	if r.pos > len(r.rows) {
+	if r.pos > r.len {
 		return io.EOF
 	}
 
-	for i, val := range r.rows[r.pos-1] {
-		dest[i] = *(val.(*interface{}))
+	row, err := r.store.Row(r.pos - 1)
+	if err != nil {
+		return err
 	}
-
+	copy(dest, row)
 	return nil
 }
 
 func (r *rows) Close() error {
-	return nil
+	if r.store == nil {
+		return nil
+	}
+	return r.store.Close()
 }
 
-func (r *rows) read(rs *sql.Rows) error {
+func (r *rows) read(c *conn, query string, rs *sql.Rows) error {
 	var err error
 	r.cols, err = rs.Columns()
 	if err != nil {
@@ -473,6 +930,8 @@ func (r *rows) read(rs *sql.Rows) error {
 		return err
 	}
 
+	r.store = c.rowStore(query, r.cols)
+
 	for rs.Next() {
 		values := make([]interface{}, len(r.cols))
 		for i := range values {
@@ -483,16 +942,20 @@ func (r *rows) read(rs *sql.Rows) error {
 		}
 		row := make([]driver.Value, len(r.cols))
 		for i, v := range values {
-			row[i] = driver.Value(v)
+			row[i] = *(v.(*interface{}))
+		}
+		if err := r.store.Append(row); err != nil {
+			return err
 		}
-		r.rows = append(r.rows, row)
+		r.len++
 	}
 	return rs.Err()
 }
 
 type rowSets struct {
-	sets []*rows
-	pos  int
+	sets  []*rows
+	pos   int
+	guard rowsGuard
 }
 
 func (rs *rowSets) Columns() []string {
@@ -508,11 +971,20 @@ func (rs *rowSets) ColumnTypeScanType(index int) reflect.Type {
 }
 
 func (rs *rowSets) Close() error {
-	return nil
+	var errs []error
+	for _, set := range rs.sets {
+		if err := set.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // advances to next row
 func (rs *rowSets) Next(dest []driver.Value) error {
+	if err := rs.guard.check(true); err != nil {
+		return err
+	}
 	return rs.sets[rs.pos].Next(dest)
 }
 
@@ -0,0 +1,21 @@
+package txdb
+
+import "sync/atomic"
+
+var defaultDriver atomic.Pointer[TxDriver]
+
+// Default returns the process-wide default driver set with SetDefault,
+// or nil if none was set. It allows helper libraries to open txdb
+// connections without passing driver names around, for example:
+//
+//	connector, _ := txdb.Default().OpenConnector(t.Name())
+//	db := sql.OpenDB(connector)
+func Default() *TxDriver {
+	return defaultDriver.Load()
+}
+
+// SetDefault sets the process-wide default driver, usually obtained with
+// [database/sql.DB.Driver]. Use nil to unset it.
+func SetDefault(d *TxDriver) {
+	defaultDriver.Store(d)
+}
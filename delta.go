@@ -0,0 +1,103 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TableDelta is the change of the contents of a table, see Delta. Rows
+// are maps from column names to values. An updated row is reported as
+// a deleted row with the old values and an inserted row with the new.
+type TableDelta struct {
+	Table    string
+	Inserted []map[string]interface{}
+	Deleted  []map[string]interface{}
+}
+
+// Delta snapshots the given tables, as visible within the transaction of
+// db, before and after calling f, and reports how their contents changed.
+// It makes side effects of triggers, like audit log entries or counters
+// maintained by them, easy to assert:
+//
+//	deltas, err := txdb.Delta(db, []string{"audit_log"}, func() error {
+//		_, err := db.Exec("DELETE FROM users WHERE id = 1")
+//		return err
+//	})
+//
+// Every table is read as a whole, so it is meant for small tables only.
+func Delta(db *sql.DB, tables []string, f func() error) ([]TableDelta, error) {
+	before := make([]snapshot, len(tables))
+	for i, table := range tables {
+		s, err := takeSnapshot(db, table)
+		if err != nil {
+			return nil, err
+		}
+		before[i] = s
+	}
+
+	if err := f(); err != nil {
+		return nil, err
+	}
+
+	deltas := make([]TableDelta, len(tables))
+	for i, table := range tables {
+		after, err := takeSnapshot(db, table)
+		if err != nil {
+			return nil, err
+		}
+		deltas[i] = TableDelta{
+			Table:    table,
+			Inserted: after.minus(before[i]),
+			Deleted:  before[i].minus(after),
+		}
+	}
+	return deltas, nil
+}
+
+// snapshot holds the rows of a table.
+type snapshot struct {
+	cols []string
+	rows [][]interface{}
+}
+
+func takeSnapshot(db *sql.DB, table string) (snapshot, error) {
+	rows, err := db.Query("SELECT * FROM " + table)
+	if err != nil {
+		return snapshot{}, fmt.Errorf("txdb: failed to snapshot %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	cols, data, err := readRows(rows, nil)
+	if err != nil {
+		return snapshot{}, fmt.Errorf("txdb: failed to snapshot %s: %w", table, err)
+	}
+	return snapshot{cols: cols, rows: data}, nil
+}
+
+// minus returns rows of s, which are not in other, duplicates included.
+func (s snapshot) minus(other snapshot) []map[string]interface{} {
+	seen := make(map[string]int, len(other.rows))
+	for _, row := range other.rows {
+		seen[rowKey(row)]++
+	}
+
+	var result []map[string]interface{}
+	for _, row := range s.rows {
+		key := rowKey(row)
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		m := make(map[string]interface{}, len(s.cols))
+		for i, col := range s.cols {
+			m[col] = row[i]
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func rowKey(row []interface{}) string {
+	return strings.Join(textValues(row, "\x00"), "\x1f")
+}
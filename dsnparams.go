@@ -0,0 +1,77 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dsnParamOptions returns the options encoded as query parameters of the
+// dsn identifier passed to Open, for example:
+//
+//	sql.Open("txdb", "mytest?savepoint=off&isolation=serializable")
+//
+// Supported parameters are "savepoint" ("on" or "off", see
+// SavePointOption), "isolation" (see WithIsolation) and "timeout" (see
+// WithStatementTimeout). They are applied after all other options, and
+// the whole dsn still identifies the connection. Other parameters, or a
+// question mark not followed by parameters, are just part of the
+// identifier. The savepoint=on parameter restores the configured
+// savepoint, or the default one, if savepoints were disabled.
+func dsnParamOptions(dsn string, configured SavePoint) ([]Option, error) {
+	i := strings.IndexByte(dsn, '?')
+	if i < 0 {
+		return nil, nil
+	}
+	params, err := url.ParseQuery(dsn[i+1:])
+	if err != nil {
+		return nil, nil // not parameters
+	}
+	if configured == nil {
+		configured = &defaultSavePoint{}
+	}
+
+	var options []Option
+	for key := range params {
+		value := params.Get(key)
+		switch key {
+		case "savepoint":
+			switch value {
+			case "on":
+				options = append(options, SavePointOption(configured))
+			case "off":
+				options = append(options, SavePointOption(nil))
+			default:
+				return nil, fmt.Errorf("txdb: dsn parameter savepoint must be on or off, got %q", value)
+			}
+		case "isolation":
+			level, err := parseIsolation(value)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, WithIsolation(level))
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("txdb: dsn parameter timeout must be a duration: %w", err)
+			}
+			options = append(options, WithStatementTimeout(timeout))
+		}
+	}
+	return options, nil
+}
+
+// parseIsolation parses an isolation level name, like "serializable" or
+// "read-committed", case and separator insensitive.
+func parseIsolation(name string) (sql.IsolationLevel, error) {
+	normalize := strings.NewReplacer(" ", "", "-", "", "_", "")
+	want := normalize.Replace(strings.ToLower(name))
+	for level := sql.LevelDefault; level <= sql.LevelLinearizable; level++ {
+		if normalize.Replace(strings.ToLower(level.String())) == want {
+			return level, nil
+		}
+	}
+	return sql.LevelDefault, fmt.Errorf("txdb: unknown isolation level %q", name)
+}
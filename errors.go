@@ -0,0 +1,48 @@
+package txdb
+
+import "errors"
+
+// Sentinel errors reported by errors.Is for errors returned by txdb. The
+// errors keep their descriptive messages, naming the connection.
+var (
+	// ErrTxClosed is returned when a nested transaction, a statement
+	// prepared within it, or rows read within it are used after the
+	// transaction was rolled back along with the root one.
+	ErrTxClosed = errors.New("txdb: transaction is closed")
+	// ErrUnsupported is returned by features which can not be faithfully
+	// emulated, under the Strict policy, see WithUnsupportedPolicy.
+	ErrUnsupported = errors.New("txdb: unsupported")
+	// ErrSavepointUnsupported is returned when a nested transaction is
+	// begun without savepoints, under the Strict policy. It is also an
+	// ErrUnsupported.
+	ErrSavepointUnsupported = errors.New("txdb: savepoints are not supported")
+	// ErrSavepointDepth is returned when the limit set with
+	// WithMaxSavePointDepth is reached.
+	ErrSavepointDepth = errors.New("txdb: savepoint depth limit reached")
+	// ErrConnPoisoned is returned when the transaction of a connection was
+	// lost or aborted, so it can not be used until all connections with
+	// its dsn identifier are closed.
+	ErrConnPoisoned = errors.New("txdb: connection is poisoned")
+	// ErrConnClosed is returned by a connection, which was reaped, see
+	// WithReaper, or whose driver was closed or unregistered.
+	ErrConnClosed = errors.New("txdb: connection is closed")
+)
+
+// kindError reports the sentinel kinds of an error, keeping its message.
+// It never wraps driver.ErrBadConn, unless the error did, so database/sql
+// does not retry it.
+type kindError struct {
+	error
+	kinds []error
+}
+
+func (e *kindError) Unwrap() []error {
+	return append([]error{e.error}, e.kinds...)
+}
+
+func withKind(err error, kinds ...error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{err, kinds}
+}
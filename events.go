@@ -0,0 +1,71 @@
+package txdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// ConnOpened is emitted whenever a connection is opened, including
+	// connections sharing an already open transaction.
+	ConnOpened EventType = iota
+	// TxBegun is emitted when the root transaction is begun.
+	TxBegun
+	// SavePointCreated is emitted when a nested transaction creates its
+	// savepoint.
+	SavePointCreated
+	// SavePointReleased is emitted when a nested transaction is committed.
+	SavePointReleased
+	// SavePointRolledBack is emitted when a nested transaction is rolled
+	// back.
+	SavePointRolledBack
+	// StmtExecuted is emitted for every successfully executed statement.
+	StmtExecuted
+	// ConnClosed is emitted whenever a connection is closed, the root
+	// transaction is rolled back when the last one is.
+	ConnClosed
+)
+
+var eventTypes = [...]string{"ConnOpened", "TxBegun", "SavePointCreated", "SavePointReleased", "SavePointRolledBack", "StmtExecuted", "ConnClosed"}
+
+func (t EventType) String() string {
+	if t < 0 || int(t) >= len(eventTypes) {
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+	return eventTypes[t]
+}
+
+// Event describes something which happened on a txdb connection.
+type Event struct {
+	Type EventType
+	// DSN is the identifier of the connection.
+	DSN string
+	// Query and Args are set for StmtExecuted events.
+	Query string
+	Args  []interface{}
+	// SavePoint is the savepoint name of savepoint events.
+	SavePoint string
+	Time      time.Time
+}
+
+// WithEvents makes connections call f for every event, so test frameworks
+// can build custom reporting on top of it. Unlike WithLogger it receives
+// structured data. f is called synchronously, possibly concurrently, with
+// txdb locks held, so it must not use the database.
+func WithEvents(f func(Event)) Option {
+	return func(cfg *Config) error {
+		cfg.onEvent = f
+		return nil
+	}
+}
+
+func (c *conn) emit(e Event) {
+	if c.onEvent == nil {
+		return
+	}
+	e.DSN, e.Time = c.dsn, time.Now()
+	c.onEvent(e)
+}
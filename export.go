@@ -0,0 +1,246 @@
+package txdb
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ExportFormat is the output format of ExportFixtures and ExportRows.
+type ExportFormat int
+
+const (
+	// ExportInserts writes an INSERT statement per row.
+	ExportInserts ExportFormat = iota
+	// ExportCSV writes a CSV header with column names and a record per row.
+	ExportCSV
+	// ExportTable writes an aligned text table, handy in test logs.
+	ExportTable
+	// ExportJSON writes a JSON array with an object per row.
+	ExportJSON
+)
+
+// ExportOptions configures ExportFixtures and ExportRows.
+type ExportOptions struct {
+	// Format is the output format, INSERT statements by default.
+	Format ExportFormat
+	// Mask replaces values of the given columns in every row, e.g. to
+	// hide emails or password hashes.
+	Mask map[string]interface{}
+}
+
+// ExportFixtures writes the contents of the given tables, as visible
+// within the transaction of db, to w. It turns a manually crafted test
+// state into a reusable fixture, for example:
+//
+//	txdb.ExportFixtures(db, os.Stdout, txdb.ExportOptions{}, "users")
+//
+// Rows are ordered by the first column.
+func ExportFixtures(db *sql.DB, w io.Writer, opts ExportOptions, tables ...string) error {
+	mysql := false
+	if drv, ok := db.Driver().(*TxDriver); ok {
+		mysql = drv.drv == "mysql"
+	}
+
+	for _, table := range tables {
+		if err := exportTable(db, w, opts, table, mysql); err != nil {
+			return fmt.Errorf("txdb: failed to export %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func exportTable(db *sql.DB, w io.Writer, opts ExportOptions, table string, mysql bool) error {
+	rows, err := db.Query("SELECT * FROM " + table + " ORDER BY 1")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return exportRows(w, rows, opts, table, mysql)
+}
+
+// ExportRows writes rows to w, for example as an aligned text table to
+// show the actual data in a failing assertion:
+//
+//	var b strings.Builder
+//	txdb.ExportRows(&b, rows, txdb.ExportOptions{Format: txdb.ExportTable})
+//	t.Log(b.String())
+//
+// The ExportInserts format is not supported, since there is no table name.
+// Rows are consumed, but not closed.
+func ExportRows(w io.Writer, rows *sql.Rows, opts ExportOptions) error {
+	if opts.Format == ExportInserts {
+		return fmt.Errorf("txdb: INSERT statements can not be exported without a table, see ExportFixtures")
+	}
+	return exportRows(w, rows, opts, "", false)
+}
+
+func exportRows(w io.Writer, rows *sql.Rows, opts ExportOptions, table string, mysql bool) error {
+	cols, data, err := readRows(rows, opts.Mask)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case ExportCSV:
+		return writeCSV(w, cols, data)
+	case ExportTable:
+		return writeTable(w, cols, data)
+	case ExportJSON:
+		return writeJSON(w, cols, data)
+	default:
+		for _, row := range data {
+			_, err := fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES (%s);\n", table, strings.Join(cols, ", "), sqlLiterals(row, mysql))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// readRows reads all rows, values of columns in mask are replaced.
+func readRows(rows *sql.Rows, mask map[string]interface{}) ([]string, [][]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data [][]interface{}
+	values := make([]interface{}, len(cols))
+	for rows.Next() {
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]interface{}, len(cols))
+		for i, col := range cols {
+			row[i] = *(values[i].(*interface{}))
+			if b, ok := row[i].([]byte); ok {
+				row[i] = string(b)
+			}
+			if v, ok := mask[col]; ok {
+				row[i] = v
+			}
+		}
+		data = append(data, row)
+	}
+	return cols, data, rows.Err()
+}
+
+func writeCSV(w io.Writer, cols []string, data [][]interface{}) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	for _, row := range data {
+		if err := cw.Write(textValues(row, "")); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeTable(w io.Writer, cols []string, data [][]interface{}) error {
+	lines := [][]string{cols}
+	for _, row := range data {
+		lines = append(lines, textValues(row, "NULL"))
+	}
+	widths := make([]int, len(cols))
+	for _, line := range lines {
+		for i, v := range line {
+			if n := utf8.RuneCountInString(v); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	for n, line := range lines {
+		for i, v := range line {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(v)
+			if i < len(line)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)))
+			}
+		}
+		b.WriteString("\n")
+		if n == 0 {
+			for i, width := range widths {
+				if i > 0 {
+					b.WriteString("-+-")
+				}
+				b.WriteString(strings.Repeat("-", width))
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeJSON(w io.Writer, cols []string, data [][]interface{}) error {
+	objects := make([]map[string]interface{}, len(data))
+	for n, row := range data {
+		objects[n] = make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			objects[n][col] = row[i]
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objects)
+}
+
+// textValues formats row values as text, null is used for NULL values.
+func textValues(row []interface{}, null string) []string {
+	text := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			text[i] = null
+		case time.Time:
+			text[i] = v.Format(time.RFC3339Nano)
+		default:
+			text[i] = fmt.Sprint(v)
+		}
+	}
+	return text
+}
+
+func sqlLiterals(row []interface{}, mysql bool) string {
+	literals := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			literals[i] = "NULL"
+		case bool:
+			literals[i] = strings.ToUpper(fmt.Sprint(v))
+		case int64, int32, int, float64, float32:
+			literals[i] = fmt.Sprint(v)
+		case time.Time:
+			literals[i] = "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+		default:
+			literals[i] = quoteString(fmt.Sprint(v), mysql)
+		}
+	}
+	return strings.Join(literals, ", ")
+}
+
+func quoteString(s string, mysql bool) string {
+	if mysql {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,86 @@
+package txdb
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// fixturesTable stores the checksums of the loaded baseline fixtures.
+const fixturesTable = "txdb_fixtures"
+
+var fixtureName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,255}$`)
+
+type baselineFixtures struct {
+	name       string
+	statements []string
+}
+
+// WithBaselineFixtures loads the given fixture statements into the real
+// database, committed and outside of any transaction, as a baseline all
+// connections share, when it is opened for the first time, right after
+// WithBootstrap. The checksum of the statements is stored under name in
+// the txdb_fixtures table, and loading is skipped while they are
+// unchanged, so test packages sharing a database do not load the same
+// fixtures over and over again.
+//
+// Once changed, the statements run again, so they must replace the rows
+// they insert, e.g. by deleting them first. They run in a transaction,
+// which DDL commits implicitly on MySQL, so use WithBootstrap for DDL.
+func WithBaselineFixtures(name string, statements ...string) Option {
+	return func(cfg *Config) error {
+		if !fixtureName.MatchString(name) {
+			return fmt.Errorf("txdb: invalid baseline fixtures name %q, only letters, digits and _.- are allowed", name)
+		}
+		cfg.fixtures = append(cfg.fixtures, baselineFixtures{name: name, statements: statements})
+		return nil
+	}
+}
+
+func (f baselineFixtures) checksum() string {
+	sum := sha256.New()
+	for _, statement := range f.statements {
+		sum.Write([]byte(statement))
+		sum.Write([]byte{0})
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// load loads the fixtures into db, unless their checksum is unchanged.
+func (f baselineFixtures) load(db *sql.DB) error {
+	checksum := f.checksum()
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + fixturesTable + " (name VARCHAR(255) PRIMARY KEY, checksum VARCHAR(64) NOT NULL)"); err != nil {
+		return fmt.Errorf("txdb: failed to create %s table: %w", fixturesTable, err)
+	}
+	where := fmt.Sprintf(" FROM %s WHERE name = '%s'", fixturesTable, f.name) // the name is validated
+	var stored string
+	err := db.QueryRow("SELECT checksum" + where).Scan(&stored)
+	switch {
+	case err == nil && stored == checksum:
+		return nil
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("txdb: failed to read checksum of %s fixtures: %w", f.name, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE" + where); err != nil {
+		return fmt.Errorf("txdb: failed to clear checksum of %s fixtures: %w", f.name, err)
+	}
+	for _, statement := range f.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("txdb: failed to load %s fixtures: %q: %w", f.name, statement, err)
+		}
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (name, checksum) VALUES ('%s', '%s')", fixturesTable, f.name, checksum)
+	if _, err := tx.Exec(insert); err != nil {
+		return fmt.Errorf("txdb: failed to store checksum of %s fixtures: %w", f.name, err)
+	}
+	return tx.Commit()
+}
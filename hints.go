@@ -0,0 +1,51 @@
+package txdb
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var hintPattern = regexp.MustCompile(`^\s*/\*\s*txdb:([\w\s,]*)\*/`)
+
+// withHints extends ctx with the behavior requested by a txdb comment hint
+// leading the query, for example:
+//
+//	/* txdb:stream,norecord */ SELECT * FROM users
+//
+// Hints elsewhere in the query, like within string literals, are ignored.
+// Supported hints are "stream" (see WithStreaming), "norecord"
+// (see NoRecord) and "direct", which executes the statement on the real
+// database outside of the transaction.
+func withHints(ctx context.Context, query string) (context.Context, error) {
+	if !strings.Contains(query, "txdb:") {
+		return ctx, nil
+	}
+	m := hintPattern.FindStringSubmatch(query)
+	if m == nil {
+		return ctx, nil
+	}
+	hints := strings.FieldsFunc(m[1], func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, hint := range hints {
+		switch hint {
+		case "stream":
+			ctx = WithStreaming(ctx)
+		case "norecord":
+			ctx = NoRecord(ctx)
+		case "direct":
+			ctx = context.WithValue(ctx, directKey, true)
+		default:
+			return ctx, fmt.Errorf("txdb: unknown query hint %q", hint)
+		}
+	}
+	return ctx, nil
+}
+
+func isDirect(ctx context.Context) bool {
+	v, _ := ctx.Value(directKey).(bool)
+	return v
+}
@@ -0,0 +1,40 @@
+package txdb
+
+import "database/sql"
+
+// defaultIsolation is the isolation level servers run transactions with,
+// unless configured otherwise.
+var defaultIsolation = map[string]sql.IsolationLevel{
+	"mysql":    sql.LevelRepeatableRead,
+	"mariadb":  sql.LevelRepeatableRead,
+	"postgres": sql.LevelReadCommitted,
+	"sqlite":   sql.LevelSerializable,
+}
+
+// rootIsolation returns the isolation level of the root transaction, the
+// one set with WithIsolation, or the default of the detected server. It
+// is LevelDefault if unknown.
+func (c *conn) rootIsolation() sql.IsolationLevel {
+	if c.Isolation != sql.LevelDefault {
+		return c.Isolation
+	}
+	version, _ := c.drv.ServerVersion()
+	return defaultIsolation[version.Product]
+}
+
+// checkIsolation checks the isolation level requested for a nested
+// transaction. Its savepoint runs with the level of the root transaction,
+// which honors the same or a weaker level, but not a stricter one.
+func (c *conn) checkIsolation(level sql.IsolationLevel) error {
+	if level == sql.LevelDefault {
+		return nil
+	}
+	root := c.rootIsolation()
+	switch {
+	case root == sql.LevelDefault:
+		return c.unsupported("isolation level %s can not be honored within a savepoint", level)
+	case level > root:
+		return c.unsupported("isolation level %s can not be honored within a savepoint of a %s transaction", level, root)
+	}
+	return nil
+}
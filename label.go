@@ -0,0 +1,47 @@
+package txdb
+
+import "database/sql"
+
+// WithConnectionLabel labels the server session running the transaction
+// with the txdb dsn identifier, so it is possible to tell which test owns
+// which session. With postgres it sets application_name to "txdb:<dsn>"
+// for the duration of the transaction, with mysql it sets the @txdb_dsn
+// user variable, which is visible in
+// performance_schema.user_variables_by_thread.
+func WithConnectionLabel() Option {
+	return func(cfg *Config) error {
+		cfg.label = true
+		return nil
+	}
+}
+
+// WithTransactionName labels the server session running the transaction,
+// like WithConnectionLabel does, but with the given name instead of the
+// dsn identifier, e.g. the name of the test package. This way operators
+// can tell which suite owns a session left idle in transaction on a
+// shared server. Combine it with WithDSNOptions to name it per dsn.
+func WithTransactionName(name string) Option {
+	return func(cfg *Config) error {
+		cfg.label = true
+		cfg.labelName = name
+		return nil
+	}
+}
+
+func (c *conn) setLabel(tx *sql.Tx) error {
+	if !c.label {
+		return nil
+	}
+	name := c.dsn
+	if c.labelName != "" {
+		name = c.labelName
+	}
+	var err error
+	switch c.drv.drv {
+	case "postgres", "pgx":
+		_, err = tx.Exec("SELECT set_config('application_name', $1, true)", "txdb:"+name)
+	case "mysql":
+		_, err = tx.Exec("SET @txdb_dsn = ?", name)
+	}
+	return err
+}
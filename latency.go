@@ -0,0 +1,64 @@
+package txdb
+
+import (
+	"time"
+)
+
+// Latency is the time a connection spent in txdb managed operations, so
+// the overhead of txdb per test can be quantified.
+type Latency struct {
+	// Begin is the time spent beginning root transactions, including the
+	// session setup and seeds.
+	Begin time.Duration
+	// SavePoints is the time spent creating, releasing and rolling back
+	// savepoints of nested transactions.
+	SavePoints time.Duration
+	// Statements is the time spent executing statements, not including
+	// reading streamed rows.
+	Statements time.Duration
+	// StatementCount is the number of statements executed.
+	StatementCount int64
+	// Rollback is the time spent in the final rollback, including the
+	// teardown, see WithTeardown.
+	Rollback time.Duration
+}
+
+// Latency returns the latency of the connection opened with the given dsn
+// identifier, or of the last one closed with it. The boolean reports
+// whether any such connection was opened.
+func (d *TxDriver) Latency(dsn string) (Latency, bool) {
+	d.Lock()
+	c, open := d.conns[dsn]
+	closed, ok := d.latencies[dsn]
+	d.Unlock()
+	if !open {
+		return closed, ok
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return c.currentLatency(), true
+}
+
+func (c *conn) currentLatency() Latency {
+	// c must be locked before call
+	l := c.latency
+	l.Statements = time.Duration(c.stmtNanos.Load())
+	l.StatementCount = c.stmtCount.Load()
+	return l
+}
+
+func (c *conn) timeStatement(start time.Time) time.Duration {
+	elapsed := time.Since(start)
+	c.stmtNanos.Add(int64(elapsed))
+	c.stmtCount.Add(1)
+	return elapsed
+}
+
+func (d *TxDriver) setLatency(dsn string, l Latency) {
+	// d must be locked before call
+	if d.latencies == nil {
+		d.latencies = make(map[string]Latency)
+	}
+	d.latencies[dsn] = l
+}
@@ -0,0 +1,50 @@
+package txdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// layerOf returns the number of txdb drivers drv is, or wraps. A txdb
+// driver registered on top of another one runs its root transaction as a
+// savepoint of the inner one, so libraries wrapping drivers can be
+// tested with txdb as well.
+func layerOf(drv driver.Driver) int {
+	if d, ok := drv.(*TxDriver); ok {
+		return d.layer + 1
+	}
+	return 0
+}
+
+// savePointID returns the id of the savepoint with the given sequence
+// number. Ids of txdb layers differ, so the savepoints of an outer layer
+// do not replace those of an inner one, which mysql does with equal names.
+func (c *conn) savePointID(seq uint) (string, error) {
+	if c.savePointNamer != nil {
+		return c.namedSavePoint(seq)
+	}
+	return c.savePointPrefix() + strconv.FormatUint(uint64(seq), 10), nil
+}
+
+// savePointSeq returns the sequence number of the savepoint with the
+// given id.
+func (c *conn) savePointSeq(id string) (uint, bool) {
+	if c.savePointNamer != nil {
+		seq, ok := c.savePointSeqs[id]
+		return seq, ok
+	}
+	if !strings.HasPrefix(id, c.savePointPrefix()) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimPrefix(id, c.savePointPrefix()), 10, 0)
+	return uint(seq), err == nil
+}
+
+func (c *conn) savePointPrefix() string {
+	if c.drv.layer == 0 {
+		return "tx_"
+	}
+	return fmt.Sprintf("tx%d_", c.drv.layer)
+}
@@ -0,0 +1,70 @@
+package txdb
+
+import (
+	"context"
+)
+
+// WithLazySavePoints defers creating the savepoint of a nested transaction
+// until the first write statement is executed within it, which saves two
+// round trips for every read-only transaction, common in ORM heavy
+// suites. Write statements are detected by their leading keyword, like
+// with WithReadOnlyEnforcement. Note, with postgres a failed statement
+// aborts the whole transaction, unless a savepoint was created before.
+func WithLazySavePoints() Option {
+	return func(cfg *Config) error {
+		cfg.lazySavePoints = true
+		return nil
+	}
+}
+
+// beforeStatement is called before every statement executed within the
+// transaction.
+func (c *conn) beforeStatement(ctx context.Context, query string) error {
+	// c must be locked before call
+	c.touch() // even if the statement fails
+	if err := c.checkContext(ctx); err != nil {
+		return err
+	}
+	if err := c.checkReadOnly(query); err != nil {
+		return err
+	}
+	if len(c.pending) == 0 || isDirect(ctx) || !writePattern.MatchString(query) {
+		return nil
+	}
+	return c.createPending(ctx)
+}
+
+// createPending creates all the pending savepoints, outermost first.
+func (c *conn) createPending(ctx context.Context) error {
+	// c must be locked before call
+	for len(c.pending) > 0 {
+		if err := c.execSavePoint(ctx, c.tx, c.SavePoint.Create(c.pending[0])); err != nil {
+			return err
+		}
+		c.emit(Event{Type: SavePointCreated, SavePoint: c.pending[0]})
+		c.pending = c.pending[1:]
+	}
+	return nil
+}
+
+func (s *stmt) beforeStatement(ctx context.Context) error {
+	// s.conn must be locked before call
+	if err := s.checkClosed(); err != nil {
+		return err
+	}
+	return s.conn.beforeStatement(ctx, s.query)
+}
+
+// endPending removes the savepoint id, and the ones nested within it,
+// from pending ones and reports whether it was pending, thus never
+// created.
+func (c *conn) endPending(id string) bool {
+	// c must be locked before call
+	for i, pending := range c.pending {
+		if pending == id {
+			c.pending = c.pending[:i]
+			return true
+		}
+	}
+	return false
+}
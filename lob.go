@@ -0,0 +1,138 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Queryer is satisfied by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// postgres large object access modes
+const (
+	invWrite = 0x20000
+	invRead  = 0x40000
+)
+
+// LargeObject is a postgres large object, opened within the transaction
+// with OpenLargeObject. It is read and written in chunks with the server
+// side large object functions, so file storage code can be tested
+// against the real database, without buffering the whole object.
+//
+// Large object descriptors are only valid within the transaction they
+// were opened in, so q must be a txdb connection or a transaction of it.
+type LargeObject struct {
+	ctx context.Context
+	q   Queryer
+	fd  int32
+}
+
+// CreateLargeObject creates an empty postgres large object and returns
+// its oid. Like any other change, it is discarded with the transaction.
+func CreateLargeObject(ctx context.Context, q Queryer) (oid uint32, err error) {
+	err = q.QueryRowContext(ctx, "SELECT lo_create(0)").Scan(&oid)
+	return oid, err
+}
+
+// OpenLargeObject opens the postgres large object with the given oid for
+// reading, or for reading and writing if write is set. ctx is used for
+// all the operations on the returned object.
+func OpenLargeObject(ctx context.Context, q Queryer, oid uint32, write bool) (*LargeObject, error) {
+	mode := invRead
+	if write {
+		mode |= invWrite
+	}
+	lo := &LargeObject{ctx: ctx, q: q}
+	if err := q.QueryRowContext(ctx, "SELECT lo_open($1, $2)", oid, mode).Scan(&lo.fd); err != nil {
+		return nil, fmt.Errorf("txdb: failed to open large object %d: %w", oid, err)
+	}
+	return lo, nil
+}
+
+// Read implements io.Reader.
+func (lo *LargeObject) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var data []byte
+	if err := lo.q.QueryRowContext(lo.ctx, "SELECT loread($1, $2)", lo.fd, len(p)).Scan(&data); err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, io.EOF
+	}
+	return copy(p, data), nil
+}
+
+// Write implements io.Writer.
+func (lo *LargeObject) Write(p []byte) (int, error) {
+	var n int
+	if err := lo.q.QueryRowContext(lo.ctx, "SELECT lowrite($1, $2)", lo.fd, p).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (lo *LargeObject) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	err := lo.q.QueryRowContext(lo.ctx, "SELECT lo_lseek64($1, $2, $3)", lo.fd, offset, whence).Scan(&pos)
+	return pos, err
+}
+
+// Close closes the large object descriptor, the object itself is kept.
+func (lo *LargeObject) Close() error {
+	_, err := lo.q.ExecContext(lo.ctx, "SELECT lo_close($1)", lo.fd)
+	return err
+}
+
+// BlobReader reads a binary column, like a MySQL LONGBLOB or a postgres
+// bytea, in chunks of the given size in bytes, so long blobs can be
+// streamed through the transaction. Every Read fetches the next
+// chunk with:
+//
+//	SELECT SUBSTRING(column, pos, size) FROM table WHERE where
+//
+// where may use the placeholders of the driver, bound to args, and must
+// select a single row.
+func BlobReader(ctx context.Context, q Queryer, table, column, where string, size int, args ...interface{}) io.Reader {
+	return &blobReader{ctx: ctx, q: q, table: table, column: column, where: where, size: size, args: args, pos: 1}
+}
+
+type blobReader struct {
+	ctx                  context.Context
+	q                    Queryer
+	table, column, where string
+	size                 int
+	args                 []interface{}
+	pos                  int64 // SUBSTRING positions start at 1
+	buf                  []byte
+	eof                  bool
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 && !r.eof {
+		if r.size <= 0 {
+			return 0, errors.New("txdb: blob chunk size must be positive")
+		}
+		query := fmt.Sprintf("SELECT SUBSTRING(%s, %d, %d) FROM %s WHERE %s", r.column, r.pos, r.size, r.table, r.where)
+		var chunk []byte
+		if err := r.q.QueryRowContext(r.ctx, query, r.args...).Scan(&chunk); err != nil {
+			return 0, fmt.Errorf("txdb: failed to read blob chunk: %w", err)
+		}
+		r.pos += int64(len(chunk))
+		r.buf, r.eof = chunk, len(chunk) < r.size
+	}
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
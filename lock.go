@@ -0,0 +1,90 @@
+package txdb
+
+import (
+	"sync"
+	"time"
+)
+
+// connLock serializes access to a connection. All statements of a
+// connection run within one transaction on a single physical connection,
+// so they can not run concurrently anyway. connLock measures how long
+// callers wait for each other and, if fair, grants access in the order
+// it was requested, so parallel goroutines in one test do not starve.
+type connLock struct {
+	mu   sync.Mutex
+	fair bool
+
+	queue   sync.Mutex // guards the tickets below, when fair
+	turn    sync.Cond
+	next    uint64
+	serving uint64
+
+	// guarded by the lock itself
+	locks   int64
+	waited  time.Duration
+	maxWait time.Duration
+}
+
+// WithFairLocking makes goroutines sharing a connection take turns in the
+// order they issued statements, instead of the default mutex behavior,
+// which may let one busy goroutine delay others for a while. Time spent
+// waiting is reported by Stats either way.
+func WithFairLocking() Option {
+	return func(cfg *Config) error {
+		cfg.fairLocking = true
+		return nil
+	}
+}
+
+func (l *connLock) Lock() {
+	start := time.Now()
+	if l.fair {
+		l.queue.Lock()
+		if l.turn.L == nil {
+			l.turn.L = &l.queue
+		}
+		ticket := l.next
+		l.next++
+		for ticket != l.serving {
+			l.turn.Wait()
+		}
+		l.queue.Unlock()
+	} else {
+		l.mu.Lock()
+	}
+
+	wait := time.Since(start)
+	l.locks++
+	l.waited += wait
+	if wait > l.maxWait {
+		l.maxWait = wait
+	}
+}
+
+// TryLock locks the connection only if it is free and reports whether it
+// did, it never waits.
+func (l *connLock) TryLock() bool {
+	if l.fair {
+		l.queue.Lock()
+		defer l.queue.Unlock()
+		if l.next != l.serving {
+			return false
+		}
+		l.next++
+	} else if !l.mu.TryLock() {
+		return false
+	}
+	l.locks++
+	return true
+}
+
+func (l *connLock) Unlock() {
+	if !l.fair {
+		l.mu.Unlock()
+		return
+	}
+	l.queue.Lock()
+	l.serving++
+	l.queue.Unlock()
+	l.turn.Broadcast()
+}
@@ -0,0 +1,74 @@
+package txdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Logger receives log messages of txdb connections, like executed
+// statements and savepoint operations.
+type Logger interface {
+	Logf(dsn, format string, args ...interface{})
+}
+
+// WithLogger sets the logger of connections.
+func WithLogger(l Logger) Option {
+	return func(cfg *Config) error {
+		cfg.Logger = l
+		return nil
+	}
+}
+
+func (c *conn) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Logf(c.dsn, format, args...)
+	}
+}
+
+// TB is the subset of [testing.TB] used by txdb test helpers.
+type TB interface {
+	Helper()
+	Logf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// TBLogger is a Logger which routes messages of a connection to the test
+// which owns its DSN, see [TBLogger.Register]. This way they are shown
+// along with the output of the right test, only when it fails or the -v
+// flag is set. Messages of connections without an owner are dropped.
+type TBLogger struct {
+	mu  sync.Mutex
+	tbs map[string]TB
+}
+
+// NewTBLogger returns a new TBLogger without any registered tests.
+func NewTBLogger() *TBLogger {
+	return &TBLogger{tbs: make(map[string]TB)}
+}
+
+// Register makes tb the owner of the given dsn identifier until tb
+// finishes.
+func (l *TBLogger) Register(dsn string, tb TB) {
+	l.mu.Lock()
+	l.tbs[dsn] = tb
+	l.mu.Unlock()
+
+	tb.Cleanup(func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.tbs[dsn] == tb {
+			delete(l.tbs, dsn)
+		}
+	})
+}
+
+// Logf satisfies the Logger interface.
+func (l *TBLogger) Logf(dsn, format string, args ...interface{}) {
+	l.mu.Lock()
+	tb, ok := l.tbs[dsn]
+	l.mu.Unlock()
+	if ok {
+		tb.Logf("txdb %s: %s", dsn, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,54 @@
+package txdb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// noteLost marks the root transaction as lost, when err reports the
+// database connection running it died, e.g. because the server was
+// restarted. Statements of a lost transaction fail with a descriptive
+// error, until all connections with its dsn identifier are closed, and
+// the next Open begins a fresh transaction.
+func (c *conn) noteLost(err error) {
+	// c must be locked before call
+	if c.lost != nil || c.tx == nil {
+		return
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		c.lost = err
+		c.logf("transaction lost: %s", err)
+	}
+}
+
+func (c *conn) lostError() error {
+	// must not wrap the error, database/sql would retry otherwise
+	return withKind(fmt.Errorf("txdb: transaction of connection %q was lost, since the database connection died: %v, close all its connections to begin a new one", c.dsn, c.lost), ErrConnPoisoned)
+}
+
+// describer returns the real connection used to describe statements,
+// reopening it if it was lost. It is nil for a pool given to RegisterDB,
+// since its DSN is not known.
+func (d *TxDriver) describer() (driver.Conn, error) {
+	// d must be locked before call
+	if d.realConn == nil && d.pool == nil {
+		realConn, err := d.openDescriber()
+		if err != nil {
+			return nil, err
+		}
+		d.realConn = realConn
+	}
+	return d.realConn, nil
+}
+
+// dropDescriber closes the real connection, if err reports it died, so
+// it is reopened on next use.
+func (d *TxDriver) dropDescriber(err error) {
+	// d must be locked before call
+	if errors.Is(err, driver.ErrBadConn) && d.realConn != nil {
+		d.realConn.Close()
+		d.realConn = nil
+	}
+}
@@ -0,0 +1,437 @@
+// Package memory is a pure Go in-memory database driver, which can be
+// used as the real driver of txdb, so a fast smoke tier of tests runs
+// without any external database. It is registered explicitly, only by
+// the tests which use it:
+//
+//	memory.Register()
+//	txdb.Register("txdb", memory.DriverName, "app", txdb.WithBootstrap(migrate))
+//
+// The dsn names the database, all connections opened with the same dsn
+// share its tables, until the last of them is closed, which frees the
+// database. The disposable database guard of txdb, WithAllowedDSNPattern,
+// does not apply to it. Only a small subset of SQL is understood, enough
+// for a typical bootstrap and simple fixtures:
+//
+//   - CREATE TABLE [IF NOT EXISTS] with column names, AUTO_INCREMENT or
+//     SERIAL, PRIMARY KEY, UNIQUE, NOT NULL and DEFAULT, other column
+//     types, constraints and table options are ignored
+//   - DROP TABLE [IF EXISTS]
+//   - INSERT INTO table [(columns)] VALUES (...), ...
+//   - SELECT columns, literals, COUNT, MIN or MAX, with an optional FROM
+//     of a single table, WHERE, ORDER BY and LIMIT [OFFSET]
+//   - UPDATE table SET column = value, ... [WHERE]
+//   - DELETE FROM table [WHERE]
+//   - SAVEPOINT, RELEASE SAVEPOINT and ROLLBACK TO SAVEPOINT
+//
+// WHERE conditions compare columns, literals and ? or $n placeholders with
+// =, <>, !=, <, <=, >, >= or IS [NOT] NULL, combined with AND, OR and
+// parentheses. Values are not converted to the column types. Statements
+// may be separated by semicolons. Every transaction works on its own copy
+// of the tables, a commit replaces the tables of the database.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriverName is the name Register registers the driver with, which txdb
+// recognizes as the in-memory database.
+const DriverName = "txdb-memory"
+
+var registerOnce sync.Once
+
+// Register registers the driver with database/sql as DriverName. It may
+// be called any number of times.
+func Register() {
+	registerOnce.Do(func() {
+		sql.Register(DriverName, Driver{})
+	})
+}
+
+var memDBs = struct {
+	sync.Mutex
+	dbs map[string]*memDB
+}{dbs: make(map[string]*memDB)}
+
+// Driver is the in-memory database driver, see Register.
+type Driver struct{}
+
+func (Driver) Open(dsn string) (driver.Conn, error) {
+	memDBs.Lock()
+	defer memDBs.Unlock()
+	db, ok := memDBs.dbs[dsn]
+	if !ok {
+		db = &memDB{dsn: dsn, tables: make(memTables)}
+		memDBs.dbs[dsn] = db
+	}
+	db.conns++
+	return &memConn{db: db}, nil
+}
+
+type memDB struct {
+	dsn    string
+	conns  int // open connections, guarded by memDBs
+	mu     sync.Mutex
+	tables memTables
+}
+
+// release frees the database once its last connection is closed.
+func (db *memDB) release() {
+	memDBs.Lock()
+	defer memDBs.Unlock()
+	if db.conns--; db.conns == 0 {
+		delete(memDBs.dbs, db.dsn)
+	}
+}
+
+// memTables are tables by their lower case names. Tables, which may be
+// shared by several copies of the tables, are never modified in place,
+// a statement modifies a copy of the table, see write.
+type memTables map[string]*memTable
+
+func (ts memTables) clone() memTables {
+	return maps.Clone(ts)
+}
+
+func (ts memTables) get(name string) (*memTable, error) {
+	t, ok := ts[name]
+	if !ok {
+		return nil, fmt.Errorf("txdb: memory table %q does not exist", name)
+	}
+	return t, nil
+}
+
+// write returns a copy of the table, which replaces it in ts, so it can
+// be modified without affecting other copies of the tables. Rows are
+// never modified in place, so only the row slice is copied.
+func (ts memTables) write(name string) (*memTable, error) {
+	t, err := ts.get(name)
+	if err != nil {
+		return nil, err
+	}
+	cp := *t
+	cp.rows = slices.Clone(t.rows)
+	ts[name] = &cp
+	return &cp, nil
+}
+
+type memTable struct {
+	name    string
+	columns []memColumn
+	rows    [][]driver.Value
+	lastID  int64
+}
+
+type memColumn struct {
+	name    string
+	autoInc bool
+	unique  bool
+	notNull bool
+	dflt    driver.Value
+}
+
+func (t *memTable) column(name string) (int, error) {
+	for i, col := range t.columns {
+		if col.name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("txdb: memory table %q has no column %q", t.name, name)
+}
+
+// check checks the constraints of row, which replaces the row at index
+// skip, or is a new row if skip is negative.
+func (t *memTable) check(row []driver.Value, skip int) error {
+	for i, col := range t.columns {
+		if row[i] == nil {
+			if col.notNull {
+				return fmt.Errorf("txdb: column %q of memory table %q can not be NULL", col.name, t.name)
+			}
+			continue
+		}
+		if !col.unique {
+			continue
+		}
+		for j, other := range t.rows {
+			if j == skip {
+				continue
+			}
+			if cmp, ok := compareValues(row[i], other[i]); ok && cmp == 0 {
+				return fmt.Errorf("txdb: duplicate value %v of unique column %q of memory table %q", row[i], col.name, t.name)
+			}
+		}
+	}
+	return nil
+}
+
+type memSavePoint struct {
+	name   string
+	tables memTables
+}
+
+type memConn struct {
+	db         *memDB
+	tx         memTables // working copy of the transaction, nil outside of it
+	savePoints []memSavePoint
+	closed     bool
+}
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	return &memStmt{conn: c, query: query}, nil
+}
+
+func (c *memConn) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.tx, c.savePoints, c.closed = nil, nil, true
+	c.db.release()
+	return nil
+}
+
+func (c *memConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// Implement the "ConnBeginTx" interface
+func (c *memConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("txdb: memory transaction is already begun")
+	}
+	c.db.mu.Lock()
+	c.tx = c.db.tables.clone()
+	c.db.mu.Unlock()
+	return memTx{c}, nil
+}
+
+// run executes the statements of query, each of them on a copy of the
+// tables, which replaces them if the statement succeeds. It returns the
+// result and the rows of the last statement.
+func (c *memConn) run(query string, args []driver.NamedValue) (res memResult, rows *memRows, err error) {
+	stmts, err := parseMemSQL(query)
+	if err != nil {
+		return res, nil, err
+	}
+	for _, st := range stmts {
+		if res, rows, err = c.runOne(st, args); err != nil {
+			return res, nil, err
+		}
+	}
+	return res, rows, nil
+}
+
+func (c *memConn) runOne(st memStatement, args []driver.NamedValue) (memResult, *memRows, error) {
+	if sp, ok := st.(memSavePointStmt); ok {
+		return memResult{}, nil, c.savePoint(sp.op, sp.name)
+	}
+	if c.tx != nil {
+		tables := c.tx.clone()
+		res, rows, err := st.exec(tables, args)
+		if err == nil {
+			c.tx = tables
+		}
+		return res, rows, err
+	}
+
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	tables := c.db.tables.clone()
+	res, rows, err := st.exec(tables, args)
+	if err == nil {
+		c.db.tables = tables
+	}
+	return res, rows, err
+}
+
+func (c *memConn) savePoint(op, name string) error {
+	if c.tx == nil {
+		return fmt.Errorf("txdb: memory %s is only allowed within a transaction", op)
+	}
+	if op == "SAVEPOINT" {
+		c.savePoints = append(c.savePoints, memSavePoint{name: name, tables: c.tx.clone()})
+		return nil
+	}
+	for i := len(c.savePoints) - 1; i >= 0; i-- {
+		if c.savePoints[i].name != name {
+			continue
+		}
+		if op == "RELEASE" {
+			c.savePoints = c.savePoints[:i]
+		} else {
+			c.tx = c.savePoints[i].tables.clone()
+			c.savePoints = c.savePoints[:i+1]
+		}
+		return nil
+	}
+	return fmt.Errorf("txdb: memory savepoint %q does not exist", name)
+}
+
+type memTx struct {
+	conn *memConn
+}
+
+func (tx memTx) Commit() error {
+	c := tx.conn
+	if c.tx == nil {
+		return sql.ErrTxDone
+	}
+	c.db.mu.Lock()
+	c.db.tables = c.tx
+	c.db.mu.Unlock()
+	c.tx, c.savePoints = nil, nil
+	return nil
+}
+
+func (tx memTx) Rollback() error {
+	c := tx.conn
+	if c.tx == nil {
+		return sql.ErrTxDone
+	}
+	c.tx, c.savePoints = nil, nil
+	return nil
+}
+
+type memStmt struct {
+	conn  *memConn
+	query string
+}
+
+func (s *memStmt) Close() error  { return nil }
+func (s *memStmt) NumInput() int { return -1 }
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+// Implement the "StmtExecContext" interface
+func (s *memStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	res, _, err := s.conn.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Implement the "StmtQueryContext" interface
+func (s *memStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	_, rows, err := s.conn.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		return &memRows{}, nil
+	}
+	return rows, nil
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type memResult struct {
+	lastID   int64
+	affected int64
+}
+
+func (r memResult) LastInsertId() (int64, error) { return r.lastID, nil }
+func (r memResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+type memRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (rs *memRows) Columns() []string { return rs.columns }
+func (rs *memRows) Close() error      { return nil }
+
+func (rs *memRows) Next(dest []driver.Value) error {
+	if rs.pos >= len(rs.rows) {
+		return io.EOF
+	}
+	copy(dest, rs.rows[rs.pos])
+	rs.pos++
+	return nil
+}
+
+// memValue copies byte slices, which the caller may reuse.
+func memValue(v driver.Value) (driver.Value, error) {
+	if b, ok := v.([]byte); ok {
+		return slices.Clone(b), nil
+	}
+	if !driver.IsValue(v) {
+		return nil, fmt.Errorf("txdb: unsupported memory value of type %T", v)
+	}
+	return v, nil
+}
+
+// compareValues compares two values, numbers numerically and strings
+// lexically. It reports false if they are not comparable, like NULL.
+func compareValues(a, b driver.Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if fa, ok := memNumber(a); ok {
+		if fb, ok := memNumber(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb), true
+		}
+	}
+	return strings.Compare(memString(a), memString(b)), true
+}
+
+func memNumber(v driver.Value) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func memString(v driver.Value) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// run executes the statements of query on tables, returning the rows of
+// the last one.
+func run(t *testing.T, tables memTables, query string, args ...driver.Value) (memResult, [][]driver.Value) {
+	t.Helper()
+	stmts, err := parseMemSQL(query)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %s", query, err)
+	}
+	var res memResult
+	var rows *memRows
+	for _, st := range stmts {
+		if res, rows, err = st.exec(tables, valuesToNamed(args)); err != nil {
+			t.Fatalf("failed to execute %q: %s", query, err)
+		}
+	}
+	if rows == nil {
+		return res, nil
+	}
+	return res, rows.rows
+}
+
+func TestShouldLexQuery(t *testing.T) {
+	toks, err := lexMemSQL("SELECT `na``me`, 'it''s' /* comment */ FROM t -- comment\nWHERE id >= ? AND n <> $2;")
+	if err != nil {
+		t.Fatalf("failed to lex: %s", err)
+	}
+	expected := []memToken{
+		{kind: memWord, text: "SELECT"},
+		{kind: memIdent, text: "na`me"},
+		{kind: memSymbol, text: ","},
+		{kind: memText, text: "it's"},
+		{kind: memWord, text: "FROM"},
+		{kind: memWord, text: "t"},
+		{kind: memWord, text: "WHERE"},
+		{kind: memWord, text: "id"},
+		{kind: memSymbol, text: ">="},
+		{kind: memParam, text: "?", ord: 1},
+		{kind: memWord, text: "AND"},
+		{kind: memWord, text: "n"},
+		{kind: memSymbol, text: "<>"},
+		{kind: memParam, text: "$2", ord: 2},
+		{kind: memSymbol, text: ";"},
+	}
+	if !reflect.DeepEqual(toks, expected) {
+		t.Fatalf("expected tokens %v, but got %v", expected, toks)
+	}
+}
+
+func TestShouldFailToParseInvalidQueries(t *testing.T) {
+	for query, msg := range map[string]string{
+		"":                                 "empty memory query",
+		" ; ":                              "empty memory query",
+		"SELECT 'open":                     "unterminated quote",
+		"SELECT 1 /* open":                 "unterminated comment",
+		"SELECT a FROM t WHERE a = #":      "unexpected character",
+		"SELECT a FROM t WHERE":            "",
+		"ALTER TABLE t ADD COLUMN a":       "",
+		"INSERT INTO t (a) VALUES (1) now": "",
+	} {
+		_, err := parseMemSQL(query)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected %q to fail with %q, but got: %v", query, msg, err)
+		}
+	}
+}
+
+func TestShouldExecuteStatements(t *testing.T) {
+	tables := make(memTables)
+	run(t, tables, `
+		CREATE TABLE users (
+			id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(32) NOT NULL,
+			email VARCHAR(64) UNIQUE,
+			age INT DEFAULT 18
+		) ENGINE=InnoDB;
+		CREATE TABLE IF NOT EXISTS users (id INT)`)
+
+	res, _ := run(t, tables, "INSERT INTO users (name, email) VALUES ('a', 'a@mail'), ('b', 'b@mail'); INSERT INTO users (name, email, age) VALUES (?, ?, ?)", "c", "c@mail", int64(30))
+	if res.lastID != 3 || res.affected != 1 {
+		t.Fatalf("expected the last id 3 and 1 affected row, but got %+v", res)
+	}
+
+	_, rows := run(t, tables, "SELECT id, name, age FROM users WHERE age > $1 OR name = 'a' ORDER BY id DESC LIMIT 2", int64(20))
+	expected := [][]driver.Value{{int64(3), "c", int64(30)}, {int64(1), "a", int64(18)}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected rows %v, but got %v", expected, rows)
+	}
+
+	res, _ = run(t, tables, "UPDATE users SET age = 40, email = NULL WHERE id <> 2")
+	if res.affected != 2 {
+		t.Fatalf("expected 2 updated rows, but got %d", res.affected)
+	}
+	_, rows = run(t, tables, "SELECT COUNT(*), MAX(age) FROM users WHERE email IS NULL")
+	if expected := [][]driver.Value{{int64(2), int64(40)}}; !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected rows %v, but got %v", expected, rows)
+	}
+
+	res, _ = run(t, tables, "DELETE FROM users WHERE (id = 1 OR id = 2) AND age = 40")
+	if res.affected != 1 {
+		t.Fatalf("expected 1 deleted row, but got %d", res.affected)
+	}
+	run(t, tables, "DROP TABLE users; DROP TABLE IF EXISTS users")
+	if len(tables) != 0 {
+		t.Fatalf("expected the table to be dropped, but got %v", tables)
+	}
+}
+
+func TestShouldEnforceConstraints(t *testing.T) {
+	tables := make(memTables)
+	run(t, tables, "CREATE TABLE users (id SERIAL PRIMARY KEY, email TEXT NOT NULL UNIQUE)")
+	run(t, tables, "INSERT INTO users (email) VALUES ('a@mail')")
+
+	for query, msg := range map[string]string{
+		"INSERT INTO users (email) VALUES ('a@mail')":  "unique",
+		"INSERT INTO users (email) VALUES (NULL)":      "null",
+		"INSERT INTO users (missing) VALUES (1)":       "column",
+		"INSERT INTO others (email) VALUES ('b@mail')": "does not exist",
+		"CREATE TABLE users (id INT)":                  "already exists",
+	} {
+		stmts, err := parseMemSQL(query)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", query, err)
+		}
+		if _, _, err := stmts[0].exec(tables, nil); err == nil || !strings.Contains(strings.ToLower(err.Error()), msg) {
+			t.Fatalf("expected %q to fail with %q, but got: %v", query, msg, err)
+		}
+	}
+}
+
+func TestShouldNotModifyTablesSharedByCopies(t *testing.T) {
+	tables := make(memTables)
+	run(t, tables, "CREATE TABLE a (id INT); CREATE TABLE b (id INT); INSERT INTO a VALUES (1)")
+
+	cp := tables.clone()
+	run(t, cp, "INSERT INTO a VALUES (2); UPDATE a SET id = 3 WHERE id = 1")
+	if cp["b"] != tables["b"] {
+		t.Fatal("expected the unmodified table to be shared by the copy")
+	}
+	if _, rows := run(t, tables, "SELECT id FROM a"); !reflect.DeepEqual(rows, [][]driver.Value{{int64(1)}}) {
+		t.Fatalf("expected the original table to stay unmodified, but got %v", rows)
+	}
+	if _, rows := run(t, cp, "SELECT id FROM a ORDER BY id"); !reflect.DeepEqual(rows, [][]driver.Value{{int64(2)}, {int64(3)}}) {
+		t.Fatalf("expected the copy to be modified, but got %v", rows)
+	}
+}
+
+func TestShouldFreeDatabaseWithLastConnection(t *testing.T) {
+	opened := func() bool {
+		memDBs.Lock()
+		defer memDBs.Unlock()
+		_, ok := memDBs.dbs["free"]
+		return ok
+	}
+	c1, _ := Driver{}.Open("free")
+	c2, _ := Driver{}.Open("free")
+	if _, _, err := c1.(*memConn).run("CREATE TABLE t (id INT)", nil); err != nil {
+		t.Fatalf("failed to create a table: %s", err)
+	}
+
+	c1.Close()
+	c1.Close()
+	if !opened() {
+		t.Fatal("expected the database to stay open with a connection left")
+	}
+	c2.Close()
+	if opened() {
+		t.Fatal("expected the database to be freed with its last connection")
+	}
+
+	c3, _ := Driver{}.Open("free")
+	defer c3.Close()
+	if _, _, err := c3.(*memConn).run("SELECT id FROM t", nil); err == nil {
+		t.Fatal("expected the tables of the freed database to be gone")
+	}
+}
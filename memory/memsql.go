@@ -0,0 +1,1128 @@
+package memory
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// memStatement is a statement of the SQL subset of the memory driver.
+type memStatement interface {
+	exec(tables memTables, args []driver.NamedValue) (memResult, *memRows, error)
+}
+
+// kinds of memToken
+const (
+	memWord = iota
+	memIdent
+	memText
+	memNum
+	memParam
+	memSymbol
+)
+
+type memToken struct {
+	kind int
+	text string
+	ord  int // ordinal of a placeholder
+}
+
+// lexMemSQL splits query into tokens, skipping comments.
+func lexMemSQL(query string) ([]memToken, error) {
+	var toks []memToken
+	params := 0
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("txdb: unterminated comment in memory query %q", query)
+			}
+			i += end + 4
+		case ch == '\'' || ch == '"' || ch == '`':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] != ch {
+					b.WriteByte(query[j])
+					continue
+				}
+				if j+1 < len(query) && query[j+1] == ch {
+					b.WriteByte(ch)
+					j++
+					continue
+				}
+				break
+			}
+			if j >= len(query) {
+				return nil, fmt.Errorf("txdb: unterminated quote in memory query %q", query)
+			}
+			kind := memIdent
+			if ch == '\'' {
+				kind = memText
+			}
+			toks = append(toks, memToken{kind: kind, text: b.String()})
+			i = j + 1
+		case ch == '?':
+			params++
+			toks = append(toks, memToken{kind: memParam, text: "?", ord: params})
+			i++
+		case ch == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			ord, _ := strconv.Atoi(query[i+1 : j])
+			toks = append(toks, memToken{kind: memParam, text: query[i:j], ord: ord})
+			i = j
+		case isDigit(ch):
+			j := i
+			for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+				j++
+			}
+			toks = append(toks, memToken{kind: memNum, text: query[i:j]})
+			i = j
+		case isWordChar(ch):
+			j := i
+			for j < len(query) && (isWordChar(query[j]) || isDigit(query[j])) {
+				j++
+			}
+			toks = append(toks, memToken{kind: memWord, text: query[i:j]})
+			i = j
+		default:
+			sym := string(ch)
+			if i+1 < len(query) {
+				switch two := query[i : i+2]; two {
+				case "<=", ">=", "<>", "!=":
+					sym = two
+				}
+			}
+			if !strings.Contains("(),;*=<>!.-", sym[:1]) {
+				return nil, fmt.Errorf("txdb: unexpected character %q in memory query %q", ch, query)
+			}
+			toks = append(toks, memToken{kind: memSymbol, text: sym})
+			i += len(sym)
+		}
+	}
+	return toks, nil
+}
+
+func isDigit(ch byte) bool { return '0' <= ch && ch <= '9' }
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
+
+// parseMemSQL parses the semicolon separated statements of query.
+func parseMemSQL(query string) ([]memStatement, error) {
+	toks, err := lexMemSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	var stmts []memStatement
+	for len(toks) > 0 {
+		end := slices.IndexFunc(toks, func(t memToken) bool { return t.kind == memSymbol && t.text == ";" })
+		if end < 0 {
+			end = len(toks)
+		}
+		if end > 0 {
+			p := &memParser{toks: toks[:end]}
+			st, err := p.statement()
+			if err == nil && p.pos < len(p.toks) {
+				err = p.unexpected()
+			}
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, st)
+		}
+		toks = toks[min(end+1, len(toks)):]
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("txdb: empty memory query")
+	}
+	return stmts, nil
+}
+
+type memParser struct {
+	toks []memToken
+	pos  int
+}
+
+func (p *memParser) peek() memToken {
+	if p.pos >= len(p.toks) {
+		return memToken{kind: memSymbol}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *memParser) unexpected() error {
+	if p.pos >= len(p.toks) {
+		return fmt.Errorf("txdb: unexpected end of memory query")
+	}
+	return fmt.Errorf("txdb: memory database does not support the query near %q", p.toks[p.pos].text)
+}
+
+// keyword consumes the words if they follow, ignoring case.
+func (p *memParser) keyword(words ...string) bool {
+	if p.pos+len(words) > len(p.toks) {
+		return false
+	}
+	for i, w := range words {
+		t := p.toks[p.pos+i]
+		if t.kind != memWord || !strings.EqualFold(t.text, w) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *memParser) expect(words ...string) error {
+	if !p.keyword(words...) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// symbol consumes the symbol if it follows.
+func (p *memParser) symbol(s string) bool {
+	if t := p.peek(); t.kind == memSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *memParser) expectSymbol(s string) error {
+	if !p.symbol(s) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// name consumes an identifier, possibly qualified, and returns its last
+// part. Unquoted identifiers are case insensitive.
+func (p *memParser) name() (string, error) {
+	var name string
+	for {
+		t := p.peek()
+		switch t.kind {
+		case memWord:
+			name = strings.ToLower(t.text)
+		case memIdent:
+			name = t.text
+		default:
+			return "", p.unexpected()
+		}
+		p.pos++
+		if !p.symbol(".") {
+			return name, nil
+		}
+	}
+}
+
+func (p *memParser) names() ([]string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.symbol(",") {
+			return names, p.expectSymbol(")")
+		}
+	}
+}
+
+func (p *memParser) statement() (memStatement, error) {
+	switch {
+	case p.keyword("CREATE", "TABLE"):
+		return p.createTable()
+	case p.keyword("DROP", "TABLE"):
+		st := &memDropTable{ifExists: p.keyword("IF", "EXISTS")}
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			st.names = append(st.names, name)
+			if !p.symbol(",") {
+				return st, nil
+			}
+		}
+	case p.keyword("INSERT", "INTO"):
+		return p.insert()
+	case p.keyword("SELECT"):
+		return p.selectFrom()
+	case p.keyword("UPDATE"):
+		return p.update()
+	case p.keyword("DELETE", "FROM"):
+		st := &memDelete{}
+		var err error
+		if st.table, err = p.name(); err != nil {
+			return nil, err
+		}
+		st.where, err = p.where()
+		return st, err
+	case p.keyword("SAVEPOINT"):
+		return p.savePoint("SAVEPOINT")
+	case p.keyword("RELEASE"):
+		p.keyword("SAVEPOINT")
+		return p.savePoint("RELEASE")
+	case p.keyword("ROLLBACK", "TO"):
+		p.keyword("SAVEPOINT")
+		return p.savePoint("ROLLBACK TO")
+	}
+	return nil, p.unexpected()
+}
+
+func (p *memParser) savePoint(op string) (memStatement, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	return memSavePointStmt{op: op, name: name}, nil
+}
+
+func (p *memParser) createTable() (memStatement, error) {
+	st := &memCreateTable{ifNotExists: p.keyword("IF", "NOT", "EXISTS")}
+	var err error
+	if st.table.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var uniques []string
+	for {
+		if p.keyword("CONSTRAINT") {
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case p.keyword("PRIMARY", "KEY"), p.keyword("UNIQUE"):
+			p.keyword("KEY")
+			p.keyword("INDEX")
+			if t := p.peek(); t.kind != memSymbol {
+				p.pos++ // index name
+			}
+			names, err := p.names()
+			if err != nil {
+				return nil, err
+			}
+			if len(names) == 1 {
+				uniques = append(uniques, names[0])
+			}
+		case p.keyword("KEY"), p.keyword("INDEX"), p.keyword("FOREIGN"), p.keyword("CHECK"):
+			p.skipDefinition()
+		default:
+			col, err := p.column()
+			if err != nil {
+				return nil, err
+			}
+			st.table.columns = append(st.table.columns, col)
+		}
+		if p.symbol(")") {
+			break
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+	p.pos = len(p.toks) // table options are ignored
+	for _, name := range uniques {
+		i, err := st.table.column(name)
+		if err != nil {
+			return nil, err
+		}
+		st.table.columns[i].unique = true
+	}
+	return st, nil
+}
+
+// skipDefinition skips the rest of a table definition item, up to the
+// comma or parenthesis ending it.
+func (p *memParser) skipDefinition() {
+	depth := 0
+	for ; p.pos < len(p.toks); p.pos++ {
+		t := p.toks[p.pos]
+		if t.kind != memSymbol {
+			continue
+		}
+		switch t.text {
+		case "(":
+			depth++
+		case ")":
+			if depth == 0 {
+				return
+			}
+			depth--
+		case ",":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *memParser) column() (memColumn, error) {
+	var col memColumn
+	var err error
+	if col.name, err = p.name(); err != nil {
+		return col, err
+	}
+	for p.pos < len(p.toks) {
+		t := p.peek()
+		if t.kind == memSymbol && (t.text == "," || t.text == ")") {
+			return col, nil
+		}
+		switch {
+		case p.keyword("PRIMARY", "KEY"):
+			col.unique, col.notNull = true, true
+		case p.keyword("UNIQUE"):
+			col.unique = true
+		case p.keyword("NOT", "NULL"):
+			col.notNull = true
+		case p.keyword("AUTO_INCREMENT"), p.keyword("AUTOINCREMENT"), p.keyword("SERIAL"), p.keyword("BIGSERIAL"), p.keyword("SMALLSERIAL"), p.keyword("IDENTITY"):
+			col.autoInc = true
+		case p.keyword("DEFAULT"):
+			e, err := p.operand()
+			if err != nil {
+				return col, err
+			}
+			if e.kind != memLiteral {
+				return col, fmt.Errorf("txdb: memory column %q default must be a literal", col.name)
+			}
+			col.dflt = e.value
+		case t.kind == memSymbol && t.text == "(":
+			p.pos++
+			p.skipDefinition() // type parameters
+			if err := p.expectSymbol(")"); err != nil {
+				return col, err
+			}
+		default:
+			p.pos++ // the type and ignored attributes
+		}
+	}
+	return col, nil
+}
+
+func (p *memParser) insert() (memStatement, error) {
+	st := &memInsert{}
+	var err error
+	if st.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == memSymbol && t.text == "(" {
+		if st.columns, err = p.names(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("VALUES"); err != nil {
+		return nil, err
+	}
+	for {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		var values []memExpr
+		for {
+			e, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, e)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		st.values = append(st.values, values)
+		if !p.symbol(",") {
+			return st, nil
+		}
+	}
+}
+
+func (p *memParser) selectFrom() (memStatement, error) {
+	st := &memSelect{}
+	for {
+		if p.symbol("*") {
+			st.items = append(st.items, memExpr{kind: memStar, text: "*"})
+		} else {
+			e, err := p.item()
+			if err != nil {
+				return nil, err
+			}
+			if p.keyword("AS") {
+				if e.text, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+			st.items = append(st.items, e)
+		}
+		if !p.symbol(",") {
+			break
+		}
+	}
+	if !p.keyword("FROM") {
+		return st, nil
+	}
+	var err error
+	if st.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if st.where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if p.keyword("ORDER", "BY") {
+		for {
+			e, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			desc := p.keyword("DESC")
+			if !desc {
+				p.keyword("ASC")
+			}
+			st.order = append(st.order, memOrder{e, desc})
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		e, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		st.limit = &e
+		if p.keyword("OFFSET") {
+			e, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			st.offset = &e
+		}
+	}
+	return st, nil
+}
+
+// item parses a select item, which may be an aggregate.
+func (p *memParser) item() (memExpr, error) {
+	for _, fn := range []string{"COUNT", "MIN", "MAX"} {
+		if t := p.peek(); t.kind != memWord || !strings.EqualFold(t.text, fn) || p.pos+1 >= len(p.toks) || p.toks[p.pos+1].text != "(" {
+			continue
+		}
+		p.pos += 2
+		e := memExpr{kind: memAggregate, fn: fn, text: fn + "(*)"}
+		if fn != "COUNT" || !p.symbol("*") {
+			arg, err := p.operand()
+			if err != nil {
+				return e, err
+			}
+			e.arg, e.text = &arg, fn+"("+arg.text+")"
+		}
+		return e, p.expectSymbol(")")
+	}
+	return p.operand()
+}
+
+// kinds of memExpr
+const (
+	memColumnRef = iota
+	memLiteral
+	memPlaceholder
+	memStar
+	memAggregate
+)
+
+type memExpr struct {
+	kind  int
+	text  string // the column name of the result
+	value driver.Value
+	ord   int
+	fn    string
+	arg   *memExpr
+}
+
+func (p *memParser) operand() (memExpr, error) {
+	t := p.peek()
+	neg := false
+	if t.kind == memSymbol && t.text == "-" {
+		p.pos++
+		neg, t = true, p.peek()
+		if t.kind != memNum {
+			return memExpr{}, p.unexpected()
+		}
+	}
+	switch t.kind {
+	case memParam:
+		p.pos++
+		return memExpr{kind: memPlaceholder, text: t.text, ord: t.ord}, nil
+	case memText:
+		p.pos++
+		return memExpr{kind: memLiteral, text: t.text, value: t.text}, nil
+	case memNum:
+		p.pos++
+		text := t.text
+		if neg {
+			text = "-" + text
+		}
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return memExpr{kind: memLiteral, text: text, value: n}, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return memExpr{}, fmt.Errorf("txdb: invalid number %q in memory query", text)
+		}
+		return memExpr{kind: memLiteral, text: text, value: f}, nil
+	case memWord:
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			p.pos++
+			return memExpr{kind: memLiteral, text: t.text}, nil
+		case "TRUE", "FALSE":
+			p.pos++
+			return memExpr{kind: memLiteral, text: t.text, value: strings.EqualFold(t.text, "TRUE")}, nil
+		}
+	}
+	name, err := p.name()
+	if err != nil {
+		return memExpr{}, err
+	}
+	return memExpr{kind: memColumnRef, text: name}, nil
+}
+
+// eval evaluates the expression on a row of t, both may be nil if there
+// is no table.
+func (e memExpr) eval(t *memTable, row []driver.Value, args []driver.NamedValue) (driver.Value, error) {
+	switch e.kind {
+	case memLiteral:
+		return e.value, nil
+	case memPlaceholder:
+		for _, arg := range args {
+			if arg.Ordinal == e.ord {
+				return memValue(arg.Value)
+			}
+		}
+		return nil, fmt.Errorf("txdb: missing argument %s of memory query", e.text)
+	case memColumnRef:
+		if t == nil {
+			return nil, fmt.Errorf("txdb: unknown column %q in memory query", e.text)
+		}
+		i, err := t.column(e.text)
+		if err != nil {
+			return nil, err
+		}
+		return row[i], nil
+	}
+	return nil, fmt.Errorf("txdb: %s is not allowed here in memory query", e.text)
+}
+
+func (p *memParser) where() (*memCond, error) {
+	if !p.keyword("WHERE") {
+		return nil, nil
+	}
+	return p.or()
+}
+
+// memCond is a condition of a WHERE clause, either an AND or OR of left
+// and right, or a comparison of a and b.
+type memCond struct {
+	op          string
+	left, right *memCond
+	a, b        memExpr
+}
+
+func (p *memParser) or() (*memCond, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("OR") {
+		var right *memCond
+		right, err = p.and()
+		left = &memCond{op: "OR", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *memParser) and() (*memCond, error) {
+	left, err := p.cond()
+	for err == nil && p.keyword("AND") {
+		var right *memCond
+		right, err = p.cond()
+		left = &memCond{op: "AND", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *memParser) cond() (*memCond, error) {
+	if p.symbol("(") {
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expectSymbol(")")
+	}
+	a, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.keyword("IS", "NOT", "NULL"):
+		return &memCond{op: "IS NOT NULL", a: a}, nil
+	case p.keyword("IS", "NULL"):
+		return &memCond{op: "IS NULL", a: a}, nil
+	}
+	t := p.peek()
+	switch t.text {
+	case "=", "<>", "!=", "<", "<=", ">", ">=":
+		if t.kind != memSymbol {
+			break
+		}
+		p.pos++
+		b, err := p.operand()
+		return &memCond{op: t.text, a: a, b: b}, err
+	}
+	return nil, p.unexpected()
+}
+
+func (c *memCond) match(t *memTable, row []driver.Value, args []driver.NamedValue) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	switch c.op {
+	case "AND", "OR":
+		ok, err := c.left.match(t, row, args)
+		if err != nil || ok == (c.op == "OR") {
+			return ok, err
+		}
+		return c.right.match(t, row, args)
+	}
+	a, err := c.a.eval(t, row, args)
+	if err != nil {
+		return false, err
+	}
+	switch c.op {
+	case "IS NULL":
+		return a == nil, nil
+	case "IS NOT NULL":
+		return a != nil, nil
+	}
+	b, err := c.b.eval(t, row, args)
+	if err != nil {
+		return false, err
+	}
+	cmp, ok := compareValues(a, b)
+	if !ok {
+		return false, nil
+	}
+	switch c.op {
+	case "=":
+		return cmp == 0, nil
+	case "<>", "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+type memSavePointStmt struct {
+	op, name string
+}
+
+func (st memSavePointStmt) exec(memTables, []driver.NamedValue) (memResult, *memRows, error) {
+	return memResult{}, nil, fmt.Errorf("txdb: memory %s must be executed by the connection", st.op)
+}
+
+type memCreateTable struct {
+	table       memTable
+	ifNotExists bool
+}
+
+func (st *memCreateTable) exec(tables memTables, _ []driver.NamedValue) (memResult, *memRows, error) {
+	if _, ok := tables[st.table.name]; ok {
+		if st.ifNotExists {
+			return memResult{}, nil, nil
+		}
+		return memResult{}, nil, fmt.Errorf("txdb: memory table %q already exists", st.table.name)
+	}
+	t := st.table
+	t.columns = slices.Clone(t.columns)
+	tables[t.name] = &t
+	return memResult{}, nil, nil
+}
+
+type memDropTable struct {
+	names    []string
+	ifExists bool
+}
+
+func (st *memDropTable) exec(tables memTables, _ []driver.NamedValue) (memResult, *memRows, error) {
+	for _, name := range st.names {
+		if _, err := tables.get(name); err != nil && !st.ifExists {
+			return memResult{}, nil, err
+		}
+		delete(tables, name)
+	}
+	return memResult{}, nil, nil
+}
+
+type memInsert struct {
+	table   string
+	columns []string
+	values  [][]memExpr
+}
+
+func (st *memInsert) exec(tables memTables, args []driver.NamedValue) (res memResult, _ *memRows, err error) {
+	t, err := tables.write(st.table)
+	if err != nil {
+		return res, nil, err
+	}
+	indexes := make([]int, len(t.columns))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if st.columns != nil {
+		indexes = indexes[:0]
+		for _, name := range st.columns {
+			i, err := t.column(name)
+			if err != nil {
+				return res, nil, err
+			}
+			indexes = append(indexes, i)
+		}
+	}
+	for _, values := range st.values {
+		if len(values) != len(indexes) {
+			return res, nil, fmt.Errorf("txdb: memory table %q insert has %d columns, but %d values", t.name, len(indexes), len(values))
+		}
+		row := make([]driver.Value, len(t.columns))
+		for i, col := range t.columns {
+			row[i] = col.dflt
+		}
+		for i, e := range values {
+			if row[indexes[i]], err = e.eval(nil, nil, args); err != nil {
+				return res, nil, err
+			}
+		}
+		for i, col := range t.columns {
+			if !col.autoInc {
+				continue
+			}
+			if id, ok := row[i].(int64); ok && id > t.lastID {
+				t.lastID = id
+			} else if row[i] == nil {
+				t.lastID++
+				row[i] = t.lastID
+			}
+			res.lastID = t.lastID
+		}
+		if err := t.check(row, -1); err != nil {
+			return res, nil, err
+		}
+		t.rows = append(t.rows, row)
+		res.affected++
+	}
+	return res, nil, nil
+}
+
+type memUpdate struct {
+	table   string
+	columns []string
+	values  []memExpr
+	where   *memCond
+}
+
+func (p *memParser) update() (memStatement, error) {
+	st := &memUpdate{}
+	var err error
+	if st.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		e, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		st.columns, st.values = append(st.columns, name), append(st.values, e)
+		if !p.symbol(",") {
+			break
+		}
+	}
+	st.where, err = p.where()
+	return st, err
+}
+
+func (st *memUpdate) exec(tables memTables, args []driver.NamedValue) (res memResult, _ *memRows, err error) {
+	t, err := tables.write(st.table)
+	if err != nil {
+		return res, nil, err
+	}
+	for i, row := range t.rows {
+		ok, err := st.where.match(t, row, args)
+		if err != nil {
+			return res, nil, err
+		}
+		if !ok {
+			continue
+		}
+		updated := slices.Clone(row)
+		for j, name := range st.columns {
+			k, err := t.column(name)
+			if err != nil {
+				return res, nil, err
+			}
+			if updated[k], err = st.values[j].eval(t, row, args); err != nil {
+				return res, nil, err
+			}
+		}
+		if err := t.check(updated, i); err != nil {
+			return res, nil, err
+		}
+		t.rows[i] = updated
+		res.affected++
+	}
+	return res, nil, nil
+}
+
+type memDelete struct {
+	table string
+	where *memCond
+}
+
+func (st *memDelete) exec(tables memTables, args []driver.NamedValue) (res memResult, _ *memRows, err error) {
+	t, err := tables.write(st.table)
+	if err != nil {
+		return res, nil, err
+	}
+	kept := t.rows[:0:0]
+	for _, row := range t.rows {
+		ok, err := st.where.match(t, row, args)
+		if err != nil {
+			return res, nil, err
+		}
+		if ok {
+			res.affected++
+		} else {
+			kept = append(kept, row)
+		}
+	}
+	t.rows = kept
+	return res, nil, nil
+}
+
+type memOrder struct {
+	expr memExpr
+	desc bool
+}
+
+type memSelect struct {
+	items         []memExpr
+	table         string
+	where         *memCond
+	order         []memOrder
+	limit, offset *memExpr
+}
+
+func (st *memSelect) exec(tables memTables, args []driver.NamedValue) (memResult, *memRows, error) {
+	rs := &memRows{}
+	if st.table == "" {
+		row := make([]driver.Value, len(st.items))
+		for i, e := range st.items {
+			v, err := e.eval(nil, nil, args)
+			if err != nil {
+				return memResult{}, nil, err
+			}
+			rs.columns, row[i] = append(rs.columns, e.text), v
+		}
+		rs.rows = append(rs.rows, row)
+		return memResult{}, rs, nil
+	}
+
+	t, err := tables.get(st.table)
+	if err != nil {
+		return memResult{}, nil, err
+	}
+	var rows [][]driver.Value
+	for _, row := range t.rows {
+		ok, err := st.where.match(t, row, args)
+		if err != nil {
+			return memResult{}, nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+	if slices.ContainsFunc(st.items, func(e memExpr) bool { return e.kind == memAggregate }) {
+		row, err := st.aggregate(t, rows, args)
+		if err != nil {
+			return memResult{}, nil, err
+		}
+		for _, e := range st.items {
+			rs.columns = append(rs.columns, e.text)
+		}
+		rs.rows = append(rs.rows, row)
+		return memResult{}, rs, nil
+	}
+	if err := st.sort(t, rows, args); err != nil {
+		return memResult{}, nil, err
+	}
+	if rows, err = st.page(rows, args); err != nil {
+		return memResult{}, nil, err
+	}
+
+	for _, e := range st.items {
+		if e.kind == memStar {
+			for _, col := range t.columns {
+				rs.columns = append(rs.columns, col.name)
+			}
+		} else {
+			rs.columns = append(rs.columns, e.text)
+		}
+	}
+	for _, row := range rows {
+		out := make([]driver.Value, 0, len(rs.columns))
+		for _, e := range st.items {
+			if e.kind == memStar {
+				out = append(out, row...)
+				continue
+			}
+			v, err := e.eval(t, row, args)
+			if err != nil {
+				return memResult{}, nil, err
+			}
+			out = append(out, v)
+		}
+		rs.rows = append(rs.rows, out)
+	}
+	return memResult{}, rs, nil
+}
+
+func (st *memSelect) aggregate(t *memTable, rows [][]driver.Value, args []driver.NamedValue) ([]driver.Value, error) {
+	out := make([]driver.Value, len(st.items))
+	for i, e := range st.items {
+		if e.kind != memAggregate {
+			return nil, fmt.Errorf("txdb: memory query can not mix %s with aggregates", e.text)
+		}
+		var count int64
+		var acc driver.Value
+		for _, row := range rows {
+			if e.arg == nil {
+				count++
+				continue
+			}
+			v, err := e.arg.eval(t, row, args)
+			if err != nil || v == nil {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			count++
+			if cmp, ok := compareValues(v, acc); acc == nil || ok && (e.fn == "MIN" && cmp < 0 || e.fn == "MAX" && cmp > 0) {
+				acc = v
+			}
+		}
+		if e.fn == "COUNT" {
+			out[i] = count
+		} else {
+			out[i] = acc
+		}
+	}
+	return out, nil
+}
+
+func (st *memSelect) sort(t *memTable, rows [][]driver.Value, args []driver.NamedValue) (err error) {
+	if len(st.order) == 0 {
+		return nil
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, o := range st.order {
+			a, aerr := o.expr.eval(t, rows[i], args)
+			b, berr := o.expr.eval(t, rows[j], args)
+			if aerr != nil || berr != nil {
+				err = fmt.Errorf("txdb: invalid ORDER BY %s in memory query", o.expr.text)
+				return false
+			}
+			cmp, ok := compareValues(a, b)
+			if !ok { // NULL first
+				if a == nil && b == nil {
+					continue
+				}
+				cmp = 1
+				if a == nil {
+					cmp = -1
+				}
+			}
+			if cmp != 0 {
+				return cmp < 0 != o.desc
+			}
+		}
+		return false
+	})
+	return err
+}
+
+func (st *memSelect) page(rows [][]driver.Value, args []driver.NamedValue) ([][]driver.Value, error) {
+	count := func(e *memExpr) (int, error) {
+		v, err := e.eval(nil, nil, args)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := v.(int64)
+		if !ok || n < 0 {
+			return 0, fmt.Errorf("txdb: memory query LIMIT and OFFSET must be non-negative integers, got %v", v)
+		}
+		return int(n), nil
+	}
+	if st.offset != nil {
+		n, err := count(st.offset)
+		if err != nil {
+			return nil, err
+		}
+		rows = rows[min(n, len(rows)):]
+	}
+	if st.limit != nil {
+		n, err := count(st.limit)
+		if err != nil {
+			return nil, err
+		}
+		rows = rows[:min(n, len(rows))]
+	}
+	return rows, nil
+}
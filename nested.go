@@ -0,0 +1,62 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// InSavePoint runs f within a nested transaction of db, which txdb backs
+// with a savepoint. The transaction is committed when f returns nil and
+// rolled back otherwise. If f panics, the transaction is rolled back
+// before the panic propagates, so a test recovering from it does not
+// continue with a half applied savepoint.
+func InSavePoint(db *sql.DB, f func(tx *sql.Tx) error) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := f(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("txdb: failed to rollback savepoint: %v, after: %w", rerr, err)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// leaveSavePoint pops the savepoint at the given level of the stack, and
+// the savepoints nested within it, which a release or a rollback to it
+// ends as well. Their own Commit and Rollback fail afterwards, except a
+// Rollback of those already rolled back.
+func (c *conn) leaveSavePoint(level int, rolledBack bool) {
+	// c must be locked before call
+	hook := c.txHooks.Commit
+	if rolledBack {
+		hook = c.txHooks.Rollback
+	}
+	for len(c.stack) > level+1 {
+		id := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		if c.ended == nil {
+			c.ended = make(map[string]bool)
+		}
+		c.ended[id] = rolledBack
+		c.pending = slices.DeleteFunc(c.pending, func(pending string) bool { return pending == id })
+		delete(c.marks, id)
+		c.leaveReadOnly(id)
+		c.txEnded(hook, id, nil)
+	}
+	c.stack = c.stack[:level]
+}
+
+func (c *conn) endedError(id string) error {
+	return withKind(fmt.Errorf("txdb: nested transaction %s of %q already ended with an enclosing transaction", id, c.dsn), ErrTxClosed)
+}
@@ -1,6 +1,154 @@
 package txdb
 
-import "fmt"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Option configures txdb connections, it is passed to Register or New.
+// Option sets can be built and shared with Options.
+type Option func(*Config) error
+
+// Config holds the settings of a txdb connection, which options write
+// into before the connection is opened. Packages can so write their own
+// options on top of the exported settings, for example:
+//
+//	func WithSlowQueries(timeout time.Duration) txdb.Option {
+//		return func(cfg *txdb.Config) error {
+//			cfg.StatementTimeout = timeout
+//			cfg.Policy = txdb.Warn
+//			return nil
+//		}
+//	}
+//
+// The other settings are only set by the options of this package.
+type Config struct {
+	// SavePoint is the syntax of savepoints, nil disables them, see
+	// SavePointOption.
+	SavePoint SavePoint
+	// Isolation is the isolation level of the root transaction, see
+	// WithIsolation.
+	Isolation sql.IsolationLevel
+	// StatementTimeout limits every statement, see WithStatementTimeout.
+	StatementTimeout time.Duration
+	// SavePointTimeout limits savepoint statements, see
+	// WithSavePointTimeout.
+	SavePointTimeout time.Duration
+	// Logger logs the statements of txdb, see WithLogger.
+	Logger Logger
+	// Policy handles features the database can not provide, see
+	// WithUnsupportedPolicy.
+	Policy Policy
+
+	dsn         string
+	allowedDSN  *regexp.Regexp
+	fairLocking bool
+
+	beginAttempts int
+	beginDelay    time.Duration
+
+	lazySavePoints bool
+
+	onEvent func(Event)
+
+	savePointNamer SavePointNamer // see WithSavePointNamer
+
+	stmtSavePoints    bool
+	stmtSavePointName func(query string) string
+
+	checkArgTypes bool
+
+	prepareQueries bool
+
+	resetPoint bool
+
+	rowsAfterClose RowsAfterClose
+
+	streamUnbounded bool
+	streamPatterns  []*regexp.Regexp
+
+	txHooks   TxHooks
+	stmtHooks StmtHooks
+
+	strictContext bool
+	role          string
+
+	dsnOptions []Option // applied after all other options
+
+	pingOnRegister bool
+
+	depthCap int // optional limit of the savepoint stack depth, zero means unlimited
+
+	recording      bool
+	recordAffected bool
+
+	reapIdle time.Duration
+	onReap   func(ConnInfo, error)
+
+	newRowStore func(query string, columns []string) RowStore // see WithRowStore
+
+	savePointlessCheck bool // see WithSavePointlessCheck
+	savePointlessHook  func(*SavePointlessError)
+
+	tunePool []func(*sql.DB) // see WithMaxOpenConns
+
+	baseCtx context.Context // see WithBaseContext
+
+	tempParent string // see WithTempDir
+	cleanups   []func() error
+
+	persistentDB bool // see WithPersistentDB
+
+	planCheck *planCheck
+
+	reportStats func(string, []QueryStat, error)
+
+	bootstrap func(*sql.DB) error
+	fixtures  []baselineFixtures // see WithBaselineFixtures
+	seeds     []func(*sql.Tx) error
+	teardowns []func(*sql.Tx) error
+
+	normalizeResults bool
+
+	diagnoseBadConn bool
+
+	label     bool
+	labelName string
+
+	sqlMode *string
+
+	restoreOnFailure bool
+
+	txBudget time.Duration
+	onExceed func(ConnInfo, time.Duration)
+
+	enforceReadOnly bool
+}
+
+// DSN returns the identifier the connection is opened with, so an option
+// can apply to some connections only, like WithDSNOptions.
+func (cfg *Config) DSN() string {
+	return cfg.dsn
+}
+
+// Options combines several options into one, applied in the given order,
+// so packages can share a common configuration:
+//
+//	var TestOptions = txdb.Options(txdb.WithLogger(logger), txdb.WithRecording())
+func Options(options ...Option) Option {
+	return func(cfg *Config) error {
+		for _, opt := range options {
+			if err := opt(cfg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
 
 // SavePoint defines the syntax to create savepoints
 // within transaction
@@ -22,13 +170,165 @@ func (dsp *defaultSavePoint) Rollback(id string) string {
 	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", id)
 }
 
+// quotedSavePoint quotes savepoint names with the quote character, which
+// is escaped by doubling it within names.
+type quotedSavePoint struct {
+	quote string
+}
+
+func (qsp quotedSavePoint) name(id string) string {
+	return qsp.quote + strings.ReplaceAll(id, qsp.quote, qsp.quote+qsp.quote) + qsp.quote
+}
+
+func (qsp quotedSavePoint) Create(id string) string {
+	return "SAVEPOINT " + qsp.name(id)
+}
+func (qsp quotedSavePoint) Release(id string) string {
+	return "RELEASE SAVEPOINT " + qsp.name(id)
+}
+func (qsp quotedSavePoint) Rollback(id string) string {
+	return "ROLLBACK TO SAVEPOINT " + qsp.name(id)
+}
+
+// BacktickSavePoint returns a SavePoint quoting names with backticks, as
+// MySQL does regardless of the ANSI_QUOTES mode, so names with unusual
+// characters do not produce syntax errors.
+func BacktickSavePoint() SavePoint {
+	return quotedSavePoint{quote: "`"}
+}
+
+// ANSISavePoint returns a SavePoint quoting names with double quotes, as
+// postgres and MySQL in the ANSI_QUOTES mode do.
+func ANSISavePoint() SavePoint {
+	return quotedSavePoint{quote: `"`}
+}
+
 // SavePointOption allows to modify the logic for
 // transaction save points. In such cases if your driver
 // does not support it, use nil. If not compatible with default
 // use custom.
-func SavePointOption(savePoint SavePoint) func(*conn) error {
-	return func(c *conn) error {
-		c.savePoint = savePoint
+//
+// To disable savepoints only for some connections of a registered driver,
+// pass it to WithDSNOptions, or open them with the savepoint=off dsn
+// parameter, like "mytest?savepoint=off". Commit and Rollback of nested
+// transactions are no-ops then, WithSavePointlessCheck reports them.
+func SavePointOption(savePoint SavePoint) Option {
+	return func(cfg *Config) error {
+		cfg.SavePoint = savePoint
+		return nil
+	}
+}
+
+// defaultAllowedDSNPattern matches database names ending with "_test".
+var defaultAllowedDSNPattern = regexp.MustCompile(`_test$`)
+
+// WithAllowedDSNPattern sets the pattern the database name of the real DSN
+// must match before txdb opens the root connection. It is a safety guard
+// against accidentally running tests on a staging or production database.
+// Only the database name is matched, the path of a URL DSN, the dbname
+// field of a postgres key=value DSN or the part after the last slash of a
+// mysql DSN, so a host, user or parameter ending with "_test" does not
+// pass the guard.
+//
+// By default only databases with a "_test" name suffix are allowed.
+// Use nil to disable the check.
+func WithAllowedDSNPattern(pattern *regexp.Regexp) Option {
+	return func(cfg *Config) error {
+		cfg.allowedDSN = pattern
+		return nil
+	}
+}
+
+// WithBootstrap sets a function called with the real database, outside of
+// any transaction, when it is opened for the first time. It is the place
+// for migrations, DDL or extension creation, which should not be rolled
+// back with the test transaction. It runs only once per driver, unless it
+// fails, in which case the open fails and the next open tries again.
+func WithBootstrap(f func(db *sql.DB) error) Option {
+	return func(cfg *Config) error {
+		cfg.bootstrap = f
+		return nil
+	}
+}
+
+// WithPingOnRegister makes the registration, or New, ping the real
+// database, so an unreachable database, or an unknown driver name, is
+// reported by every Open with a descriptive error, rather than by some
+// unrelated test. Without it, the driver name and the DSN syntax are
+// validated by the first Open, so the real driver may be registered
+// later.
+func WithPingOnRegister() Option {
+	return func(cfg *Config) error {
+		cfg.pingOnRegister = true
+		return nil
+	}
+}
+
+// WithDSNOptions applies the given options only to connections opened
+// with the dsn identifier, after all other options. So a single registered
+// driver can behave differently for well known identifiers used across
+// a suite, for example:
+//
+//	txdb.Register("txdb", "mysql", "root@/txdb_test",
+//		txdb.WithDSNOptions("legacy", txdb.SavePointOption(nil)),
+//		txdb.WithDSNOptions("audit", txdb.WithRecording()),
+//	)
+func WithDSNOptions(dsn string, options ...Option) Option {
+	return func(cfg *Config) error {
+		if cfg.dsn != dsn {
+			return nil
+		}
+		cfg.dsnOptions = append(cfg.dsnOptions, options...)
+		return nil
+	}
+}
+
+// WithIsolation sets the isolation level of the root transaction, which
+// nested transactions can not change, since they are savepoints.
+func WithIsolation(level sql.IsolationLevel) Option {
+	return func(cfg *Config) error {
+		cfg.Isolation = level
+		return nil
+	}
+}
+
+// WithSavePointTimeout bounds how long a single savepoint statement may
+// run, so a hung savepoint creation, release or rollback fails with a
+// descriptive error instead of blocking the test forever. Zero means no
+// timeout.
+func WithSavePointTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) error {
+		cfg.SavePointTimeout = timeout
+		return nil
+	}
+}
+
+// WithStatementTimeout applies a default timeout to every statement
+// executed with a context, unless the context already has an earlier
+// deadline, so a hanging query fails instead of blocking the test
+// forever. Like any canceled context, a timed out statement aborts the
+// transaction. Streamed rows must be read within the timeout as well.
+// Zero means no timeout.
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) error {
+		if timeout < 0 {
+			return fmt.Errorf("txdb: statement timeout must not be negative, got %s", timeout)
+		}
+		cfg.StatementTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxSavePointDepth limits how deeply transactions may be nested on
+// a single connection. Beginning a transaction beyond the limit returns
+// an error instead of creating yet another savepoint, which helps to
+// spot runaway recursive transaction helpers. Zero means unlimited.
+func WithMaxSavePointDepth(n int) Option {
+	return func(cfg *Config) error {
+		if n < 0 {
+			return fmt.Errorf("txdb: savepoint depth limit must not be negative, got %d", n)
+		}
+		cfg.depthCap = n
 		return nil
 	}
 }
@@ -0,0 +1,75 @@
+package txdb
+
+import (
+	"strings"
+	"sync"
+)
+
+// ParallelAudit detects DSN identifiers shared by tests running at the
+// same time, which almost always is an isolation bug of the setup helpers
+// of a suite, like a fixed DSN used by tests calling t.Parallel. The
+// helpers report every DSN they open with [ParallelAudit.Use].
+//
+// A subtest may use the DSN of its parent test, if the test reports its
+// name like [testing.TB] does.
+type ParallelAudit struct {
+	mu    sync.Mutex
+	users map[string][]TB
+}
+
+// NewParallelAudit returns a new ParallelAudit without any DSNs in use.
+func NewParallelAudit() *ParallelAudit {
+	return &ParallelAudit{users: make(map[string][]TB)}
+}
+
+// Use notes that tb uses the dsn identifier until tb finishes. It fails
+// tb if another test, which did not finish yet, uses the dsn too.
+func (a *ParallelAudit) Use(dsn string, tb TB) {
+	tb.Helper()
+	a.mu.Lock()
+	for _, other := range a.users[dsn] {
+		if other == tb {
+			a.mu.Unlock()
+			return
+		}
+		if !isSubtest(other, tb) {
+			a.mu.Unlock()
+			tb.Fatalf("txdb: dsn %q is used by test %s and %s at the same time, use a distinct dsn for every parallel test, see UniqueDSN", dsn, tbName(other), tbName(tb))
+			return
+		}
+	}
+	a.users[dsn] = append(a.users[dsn], tb)
+	a.mu.Unlock()
+
+	tb.Cleanup(func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		users := a.users[dsn]
+		for i, user := range users {
+			if user == tb {
+				a.users[dsn] = append(users[:i:i], users[i+1:]...)
+				break
+			}
+		}
+		if len(a.users[dsn]) == 0 {
+			delete(a.users, dsn)
+		}
+	})
+}
+
+func tbName(tb TB) string {
+	if named, ok := tb.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return "<unnamed>"
+}
+
+// isSubtest reports whether child is a subtest of parent.
+func isSubtest(parent, child TB) bool {
+	p, ok := parent.(interface{ Name() string })
+	if !ok {
+		return false
+	}
+	c, ok := child.(interface{ Name() string })
+	return ok && strings.HasPrefix(c.Name(), p.Name()+"/")
+}
@@ -0,0 +1,13 @@
+package txdb
+
+// WithPersistentDB keeps the real database open once the last connection
+// is closed, so its pool stays warm for the connections opened by the
+// following tests, instead of reconnecting every time. It is closed by
+// TxDriver.Close or Unregister only, which a suite calls once all tests
+// are done, e.g. from TestMain.
+func WithPersistentDB() Option {
+	return func(cfg *Config) error {
+		cfg.persistentDB = true
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package txdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type planCheck struct {
+	match  *regexp.Regexp
+	store  *planStore
+	update bool
+}
+
+// planStore holds expected plan hashes by query, persisted in a JSON file.
+type planStore struct {
+	mu     sync.Mutex
+	file   string
+	hashes map[string]string
+}
+
+var planStores = struct {
+	sync.Mutex
+	byFile map[string]*planStore
+}{byFile: make(map[string]*planStore)}
+
+// WithPlanCheck makes connections detect query plan regressions, e.g. when
+// an index stops being used after a schema or query change. Before a
+// statement matching the pattern is executed, its plan is explained,
+// normalized and hashed, then compared with the expectation stored in the
+// JSON file. The statement fails if the plan has changed.
+//
+// Missing expectations are added to the file, so the first run records
+// the baseline. With update set, changed expectations are overwritten
+// instead of failing.
+func WithPlanCheck(match *regexp.Regexp, file string, update bool) Option {
+	return func(cfg *Config) error {
+		store, err := loadPlanStore(file)
+		if err != nil {
+			return err
+		}
+		cfg.planCheck = &planCheck{match: match, store: store, update: update}
+		return nil
+	}
+}
+
+func loadPlanStore(file string) (*planStore, error) {
+	planStores.Lock()
+	defer planStores.Unlock()
+	if store, ok := planStores.byFile[file]; ok {
+		return store, nil
+	}
+
+	store := &planStore{file: file, hashes: make(map[string]string)}
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("txdb: failed to read plan expectations: %w", err)
+	default:
+		if err := json.Unmarshal(data, &store.hashes); err != nil {
+			return nil, fmt.Errorf("txdb: failed to parse plan expectations %s: %w", file, err)
+		}
+	}
+	planStores.byFile[file] = store
+	return store, nil
+}
+
+func (s *planStore) verify(query, plan string, update bool) error {
+	sum := sha256.Sum256([]byte(plan))
+	hash := hex.EncodeToString(sum[:8])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expected, ok := s.hashes[query]
+	if ok && expected == hash {
+		return nil
+	}
+	if ok && !update {
+		return fmt.Errorf("txdb: plan of %q has changed, expected hash %s, but got %s for plan:\n%s", query, expected, hash, plan)
+	}
+
+	s.hashes[query] = hash
+	data, err := json.MarshalIndent(s.hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, data, 0o644)
+}
+
+// checkPlan verifies the plan of query, see WithPlanCheck.
+func (c *conn) checkPlan(ctx context.Context, tx *sql.Tx, query string, args []interface{}) error {
+	// c must be locked before call
+	if c.planCheck == nil || !c.planCheck.match.MatchString(query) {
+		return nil
+	}
+	plan, err := c.explain(ctx, tx, query, args)
+	if err != nil {
+		return fmt.Errorf("txdb: failed to explain %q: %w", query, err)
+	}
+	return c.planCheck.store.verify(query, plan, c.planCheck.update)
+}
+
+// explain returns the plan of query without estimates, which vary.
+func (c *conn) explain(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (string, error) {
+	prefix := "EXPLAIN "
+	if c.drv.drv == "postgres" || c.drv.drv == "pgx" {
+		prefix = "EXPLAIN (COSTS OFF) "
+	}
+	rows, err := tx.QueryContext(ctx, prefix+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var plan strings.Builder
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		if err := rows.Scan(values...); err != nil {
+			return "", err
+		}
+		for i, col := range cols {
+			switch strings.ToLower(col) {
+			case "rows", "filtered":
+				continue // estimates
+			}
+			v := *(values[i].(*interface{}))
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			fmt.Fprintf(&plan, "%v|", v)
+		}
+		plan.WriteString("\n")
+	}
+	return plan.String(), rows.Err()
+}
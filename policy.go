@@ -0,0 +1,56 @@
+package txdb
+
+import (
+	"fmt"
+	"log"
+)
+
+// Policy defines what txdb does when the code under test uses a feature,
+// which can not be faithfully emulated within a transaction.
+type Policy int
+
+const (
+	// Ignore silently degrades the feature, which is the default. Known
+	// problematic DSNs are still reported with a warning.
+	Ignore Policy = iota
+	// Warn degrades the feature and logs a warning with the connection
+	// logger, see WithLogger, or with the standard logger if none is set.
+	Warn
+	// Strict returns an error instead.
+	Strict
+)
+
+// WithUnsupportedPolicy sets the policy applied when a feature can not be
+// faithfully emulated, which is:
+//   - a nested transaction, when savepoints are disabled, since it can
+//     not be rolled back
+//   - a transaction with an isolation level stricter than the one of the
+//     root transaction, which the savepoint runs with
+//   - a read-only transaction, unless WithReadOnlyEnforcement is set
+//   - multiple result sets of a streamed query, see WithStreaming
+//   - a driver and DSN combination known to be problematic, like a mysql
+//     DSN without multiStatements=true, reported at registration, with a
+//     warning of the standard logger unless the policy is Strict
+func WithUnsupportedPolicy(p Policy) Option {
+	return func(cfg *Config) error {
+		cfg.Policy = p
+		return nil
+	}
+}
+
+// unsupported applies the policy to the described use of an unsupported
+// feature, it returns an error only with the Strict policy.
+func (c *conn) unsupported(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	switch c.Policy {
+	case Strict:
+		return withKind(fmt.Errorf("txdb: %s, see WithUnsupportedPolicy", msg), ErrUnsupported)
+	case Warn:
+		if c.Logger != nil {
+			c.logf("warning: %s", msg)
+		} else {
+			log.Printf("txdb %s: warning: %s", c.dsn, msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+)
+
+// RegisterDB registers a txdb sql driver under the given name like
+// [Register], but instead of opening the real database by driver name and
+// DSN, it begins transactions on the given pool. This allows connector
+// settings, which can not be expressed as a DSN, like a TLS config or
+// cloud IAM authentication.
+//
+// The pool is owned by the caller and never closed by txdb. Since the DSN
+// is not known, the disposable database guard, see WithAllowedDSNPattern,
+// does not apply, so db must point to a disposable database.
+func RegisterDB(name string, db *sql.DB, options ...Option) *TxDriver {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	return register(name, newPoolDriver(db, options))
+}
+
+func newPoolDriver(db *sql.DB, options []Option) *TxDriver {
+	d := &TxDriver{
+		drv:     driverName(db.Driver()),
+		pool:    db,
+		layer:   layerOf(db.Driver()),
+		conns:   make(map[string]*conn),
+		options: options,
+	}
+	if err := d.validate(); err != nil {
+		d.err = d.invalid(err)
+	}
+	return d
+}
+
+// RegisterConnector registers a txdb sql driver under the given name like
+// [Register], but opens the real database with the given connector, like
+// the ones of mysql.NewConnector, whose config can not be expressed as a
+// DSN. Since the DSN is not known, the disposable database guard, see
+// WithAllowedDSNPattern, does not apply.
+func RegisterConnector(name string, connector driver.Connector, options ...Option) *TxDriver {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	return register(name, newConnectorDriver(connector, options))
+}
+
+// NewFromConnector returns a [database/sql/driver.Connector] like [New],
+// which opens the real database with the given connector.
+func NewFromConnector(connector driver.Connector, options ...Option) driver.Connector {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	return &txConnector{
+		driver: newConnectorDriver(connector, options),
+		name:   "connector",
+	}
+}
+
+func newConnectorDriver(connector driver.Connector, options []Option) *TxDriver {
+	d := &TxDriver{
+		drv:       driverName(connector.Driver()),
+		connector: connector,
+		layer:     layerOf(connector.Driver()),
+		conns:     make(map[string]*conn),
+		options:   options,
+	}
+	if err := d.validate(); err != nil {
+		d.err = d.invalid(err)
+	}
+	return d
+}
+
+// knownDrivers are the names of the drivers txdb has specific support
+// for, by the package of their driver type.
+var knownDrivers = map[string]string{
+	"github.com/lib/pq":                  "postgres",
+	"github.com/jackc/pgx/v4/stdlib":     "pgx",
+	"github.com/jackc/pgx/v5/stdlib":     "pgx",
+	"github.com/go-sql-driver/mysql":     "mysql",
+	"github.com/mattn/go-sqlite3":        "sqlite3",
+	"modernc.org/sqlite":                 "sqlite",
+	"github.com/DATA-DOG/go-txdb/memory": memoryDriver,
+}
+
+// memoryDriver is the name of the in-memory database driver of the memory
+// package, whose databases are freed when their last connection closes.
+const memoryDriver = "txdb-memory"
+
+// driverName returns the name drv is registered with in database/sql, so
+// driver specific features work with a pool or connector too, or an empty
+// string if it is not a driver txdb has specific support for.
+func driverName(drv driver.Driver) string {
+	// registerMu must be locked before call
+	switch drv := drv.(type) {
+	case *TxDriver:
+		return drv.name
+	}
+	t := reflect.TypeOf(drv)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return knownDrivers[t.PkgPath()]
+}
+
+// openReal opens the real database, or returns the pool given to
+// RegisterDB, which must be released with closeReal.
+func (d *TxDriver) openReal() (*sql.DB, error) {
+	switch {
+	case d.pool != nil:
+		return d.pool, nil
+	case d.connector != nil:
+		return sql.OpenDB(d.connector), nil
+	}
+	return sql.Open(d.drv, d.dsn)
+}
+
+// openDescriber opens a real connection outside of any pool.
+func (d *TxDriver) openDescriber() (driver.Conn, error) {
+	if d.connector != nil {
+		return d.connector.Connect(context.Background())
+	}
+	return d.db.Driver().Open(d.dsn)
+}
+
+// closeReal closes db opened with openReal, unless it is the pool owned by
+// the caller of RegisterDB.
+func (d *TxDriver) closeReal(db *sql.DB) error {
+	if db == d.pool {
+		return nil
+	}
+	return db.Close()
+}
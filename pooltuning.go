@@ -0,0 +1,38 @@
+package txdb
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WithMaxOpenConns limits the number of open connections of the real
+// database, see [database/sql.DB.SetMaxOpenConns]. Every DSN holds one of
+// them for its transaction, so the limit is the number of DSNs used at
+// once, beyond it beginning a transaction waits for another DSN to close.
+// Pool options are ignored for a pool given to RegisterDB.
+func WithMaxOpenConns(n int) Option {
+	return func(cfg *Config) error {
+		cfg.tunePool = append(cfg.tunePool, func(db *sql.DB) { db.SetMaxOpenConns(n) })
+		return nil
+	}
+}
+
+// WithMaxIdleConns limits the number of idle connections kept by the real
+// database, see [database/sql.DB.SetMaxIdleConns].
+func WithMaxIdleConns(n int) Option {
+	return func(cfg *Config) error {
+		cfg.tunePool = append(cfg.tunePool, func(db *sql.DB) { db.SetMaxIdleConns(n) })
+		return nil
+	}
+}
+
+// WithConnMaxLifetime limits the time a connection of the real database
+// is reused, see [database/sql.DB.SetConnMaxLifetime], e.g. to close idle
+// connections before a server side timeout does. Connections holding a
+// transaction are closed only once it ends.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(cfg *Config) error {
+		cfg.tunePool = append(cfg.tunePool, func(db *sql.DB) { db.SetConnMaxLifetime(d) })
+		return nil
+	}
+}
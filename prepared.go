@@ -0,0 +1,54 @@
+package txdb
+
+import "fmt"
+
+// track notes a statement prepared within the transaction, so it can be
+// closed once the savepoint it was prepared in is rolled back.
+func (c *conn) track(s *stmt) *stmt {
+	// c must be locked before call
+	s.saves = c.saves
+	if c.prepared == nil {
+		c.prepared = make(map[*stmt]struct{})
+	}
+	c.prepared[s] = struct{}{}
+	return s
+}
+
+func (c *conn) untrack(s *stmt) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.prepared, s)
+}
+
+// closePrepared closes statements prepared since the savepoint with the
+// given id was created, when it is rolled back. Otherwise they would leak
+// on the server, until the whole transaction ends, and closing them later
+// could interfere with a subsequent rollback.
+func (c *conn) closePrepared(id string) {
+	// c must be locked before call
+	seq, ok := c.savePointSeq(id)
+	if !ok {
+		return
+	}
+	c.closePreparedSince(seq, "savepoint "+id)
+}
+
+func (c *conn) closePreparedSince(seq uint, by string) {
+	// c must be locked before call
+	for s := range c.prepared {
+		if s.saves < seq {
+			continue
+		}
+		delete(c.prepared, s)
+		s.closedBy = by
+		s.st.Close()
+	}
+}
+
+func (s *stmt) checkClosed() error {
+	// s.conn must be locked before call
+	if s.closedBy != "" {
+		return withKind(fmt.Errorf("txdb: statement %q was closed by the rollback of %s it was prepared in", s.query, s.closedBy), ErrTxClosed)
+	}
+	return nil
+}
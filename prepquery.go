@@ -0,0 +1,42 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+)
+
+var selectPattern = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(?:SELECT|WITH)\b`)
+
+// WithPreparedQueries makes SELECT queries run as prepared statements,
+// which are cached for the transaction, instead of being sent directly.
+// This emulates ORMs and drivers configured to prepare statements in
+// production, so plan and placeholder related bugs surface in tests.
+func WithPreparedQueries() Option {
+	return func(cfg *Config) error {
+		cfg.prepareQueries = true
+		return nil
+	}
+}
+
+// queryTx runs the query within tx, as a cached prepared statement if
+// requested, see WithPreparedQueries.
+func (c *conn) queryTx(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (*sql.Rows, error) {
+	// c must be locked before call
+	if !c.prepareQueries || !selectPattern.MatchString(query) {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	if c.queryStmtsTx != tx {
+		// statements of a previous transaction were closed with it
+		c.queryStmts, c.queryStmtsTx = make(map[string]*sql.Stmt), tx
+	}
+	st, ok := c.queryStmts[query]
+	if !ok {
+		var err error
+		if st, err = tx.PrepareContext(ctx, query); err != nil {
+			return nil, err
+		}
+		c.queryStmts[query] = st
+	}
+	return st.QueryContext(ctx, args...)
+}
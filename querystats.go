@@ -0,0 +1,118 @@
+package txdb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// QueryStat contains server side statistics of a normalized statement.
+type QueryStat struct {
+	// Digest is the server side identifier of the normalized statement.
+	Digest string
+	// Query is the normalized statement text.
+	Query string
+	// Calls is the number of times the statement was executed.
+	Calls int64
+	// TotalTime is the total time spent executing the statement.
+	TotalTime time.Duration
+}
+
+const (
+	pgQueryStats = `SELECT COALESCE(queryid::text, ''), MIN(query), SUM(calls), SUM(total_exec_time)
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		GROUP BY queryid`
+
+	// total_exec_time was named total_time before postgres 13
+	pgLegacyQueryStats = `SELECT COALESCE(queryid::text, ''), MIN(query), SUM(calls), SUM(total_time)
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		GROUP BY queryid`
+
+	mysqlQueryStats = `SELECT COALESCE(DIGEST, ''), COALESCE(DIGEST_TEXT, ''), COUNT_STAR, SUM_TIMER_WAIT / 1000000000
+		FROM performance_schema.events_statements_summary_by_digest
+		WHERE SCHEMA_NAME = DATABASE()`
+)
+
+// WithQueryStats snapshots server side statement statistics, from
+// pg_stat_statements on Postgres or performance_schema on MySQL and
+// MariaDB, when the connection is opened and closed, and
+// reports the difference, so that per test query statistics can be
+// tracked.
+//
+// Statistics are collected server wide, so statements of connections
+// running in parallel are reported as well.
+func WithQueryStats(report func(dsn string, stats []QueryStat, err error)) Option {
+	return func(cfg *Config) error {
+		cfg.reportStats = report
+		return nil
+	}
+}
+
+func (d *TxDriver) queryStats() (map[string]QueryStat, error) {
+	// d must be locked before call
+	var query string
+	switch d.drv {
+	case "postgres", "pgx":
+		query = pgQueryStats
+		if v := d.serverVersion(); v.Product == "postgres" && !v.AtLeast(13, 0) {
+			query = pgLegacyQueryStats
+		}
+	case "mysql":
+		query = mysqlQueryStats
+	default:
+		return nil, fmt.Errorf("txdb: query statistics are not supported for %s driver", d.drv)
+	}
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("txdb: failed to read query statistics: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]QueryStat)
+	for rows.Next() {
+		var stat QueryStat
+		var ms float64
+		if err := rows.Scan(&stat.Digest, &stat.Query, &stat.Calls, &ms); err != nil {
+			return nil, err
+		}
+		stat.TotalTime = time.Duration(ms * float64(time.Millisecond))
+		stats[stat.Digest] = stat
+	}
+	return stats, rows.Err()
+}
+
+// diffQueryStats returns statements executed between the snapshots,
+// the most frequently called first.
+func diffQueryStats(before, after map[string]QueryStat) []QueryStat {
+	var diff []QueryStat
+	for digest, stat := range after {
+		prev := before[digest]
+		if stat.Calls > prev.Calls {
+			stat.Calls -= prev.Calls
+			stat.TotalTime -= prev.TotalTime
+			diff = append(diff, stat)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		if diff[i].Calls != diff[j].Calls {
+			return diff[i].Calls > diff[j].Calls
+		}
+		return diff[i].Digest < diff[j].Digest
+	})
+	return diff
+}
+
+func (c *conn) queryStatsReport() func() {
+	// c driver must be locked before call
+	stats, err := c.drv.queryStats()
+	if c.statsErr != nil {
+		err = c.statsErr
+	}
+	diff := diffQueryStats(c.statsBefore, stats)
+	return func() {
+		c.reportStats(c.dsn, diff, err)
+	}
+}
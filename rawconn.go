@@ -0,0 +1,64 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// RawConn calls f with the real driver connection the root transaction of
+// the txdb connection behind sc runs on, so driver specific features, like
+// pq.CopyIn or pgx extensions, can be used within the transaction. The
+// transaction is begun first, if it was not yet, and pending savepoints,
+// see WithLazySavePoints, are created. The txdb connection is locked while
+// f runs, so f must not use sc, and the driver connection must not be
+// used after f returns, nor its transaction ended.
+func RawConn(sc *sql.Conn, f func(driverConn interface{}) error) error {
+	return sc.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return fmt.Errorf("txdb: %T is not a txdb connection", driverConn)
+		}
+		c.Lock()
+		defer c.Unlock()
+
+		if _, err := c.beginOnce(); err != nil {
+			return err
+		}
+		if err := c.createPending(c.base()); err != nil {
+			return err
+		}
+		return c.rootConn.Raw(f)
+	})
+}
+
+// beginPinned begins a transaction on a connection pinned from the real
+// database, so the driver connection stays reachable with Raw. Like
+// database/sql, it tries again with another connection if it was bad.
+func (c *conn) beginPinned(ctx context.Context) (sc *sql.Conn, tx *sql.Tx, err error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		if sc, err = c.drv.db.Conn(ctx); err != nil {
+			return nil, nil, err
+		}
+		if tx, err = sc.BeginTx(ctx, &sql.TxOptions{Isolation: c.Isolation}); err == nil {
+			return sc, tx, nil
+		}
+		sc.Close()
+		if !errors.Is(err, driver.ErrBadConn) {
+			break
+		}
+	}
+	return nil, nil, err
+}
+
+// releaseRoot returns the connection pinned by the root transaction to
+// the pool, once the transaction ended.
+func (c *conn) releaseRoot() {
+	// c must be locked before call
+	if c.rootConn != nil {
+		c.rootConn.Close()
+		c.rootConn = nil
+	}
+}
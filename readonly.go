@@ -0,0 +1,45 @@
+package txdb
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// writePattern matches statements, which modify data or schema, by their
+// leading keyword, skipping leading comments like hints.
+var writePattern = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(?:INSERT|UPDATE|DELETE|REPLACE|MERGE|UPSERT|CREATE|ALTER|DROP|TRUNCATE|RENAME|GRANT|REVOKE|COPY|CALL)\b`)
+
+// WithReadOnlyEnforcement makes nested transactions begun with ReadOnly
+// set in sql.TxOptions reject write statements until the transaction
+// ends. Savepoints can not be made read-only, so without this option the
+// flag is silently ignored. Write statements are detected by their
+// leading keyword, so the enforcement is best effort.
+func WithReadOnlyEnforcement() Option {
+	return func(cfg *Config) error {
+		cfg.enforceReadOnly = true
+		return nil
+	}
+}
+
+func (c *conn) enterReadOnly(id string) {
+	// c must be locked before call
+	// nested transactions of a read-only transaction stay read-only
+	if c.enforceReadOnly && c.readOnly == "" {
+		c.readOnly = id
+	}
+}
+
+func (c *conn) leaveReadOnly(id string) {
+	// c must be locked before call
+	if c.readOnly == id {
+		c.readOnly = ""
+	}
+}
+
+func (c *conn) checkReadOnly(query string) error {
+	// c must be locked before call
+	if c.readOnly != "" && writePattern.MatchString(query) {
+		return fmt.Errorf("txdb: statement %q rejected, since the transaction is read-only", query)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package txdb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Parameters of real driver DSNs, which txdb relies on or checks.
+const (
+	// MySQLMultiStatements allows multiple statements within a single
+	// query, which multiple result sets and ExecBatch rely on.
+	MySQLMultiStatements = "multiStatements"
+	// MySQLParseTime makes DATE and DATETIME columns scan into time.Time.
+	MySQLParseTime = "parseTime"
+	// PostgresSSLMode selects whether and how SSL is used, local test
+	// databases usually run without it.
+	PostgresSSLMode = "sslmode"
+)
+
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// MySQLDSN returns a go-sql-driver/mysql DSN of the dbname database at
+// addr, a host:port pair, with multiStatements=true and parseTime=true.
+// params are added to them, or override them. The DSN is checked with
+// CheckDSN before it is returned.
+func MySQLDSN(user, password, addr, dbname string, params url.Values) (string, error) {
+	if addr == "" {
+		return "", errors.New("txdb: mysql DSN requires an address")
+	}
+	query := url.Values{MySQLMultiStatements: {"true"}, MySQLParseTime: {"true"}}
+	for key, values := range params {
+		query[key] = values
+	}
+	auth := user
+	if password != "" {
+		auth += ":" + password
+	}
+	dsn := fmt.Sprintf("%s@tcp(%s)/%s?%s", auth, addr, dbname, query.Encode())
+	return dsn, CheckDSN("mysql", dsn)
+}
+
+// PostgresDSN returns a postgres URL DSN of the dbname database at addr,
+// a host:port pair, with sslmode=disable if the host is local. params are
+// added to it, or override it. The DSN is checked with CheckDSN before it
+// is returned.
+func PostgresDSN(user, password, addr, dbname string, params url.Values) (string, error) {
+	if addr == "" {
+		return "", errors.New("txdb: postgres DSN requires an address")
+	}
+	query := url.Values{}
+	if isLocalAddr(addr) {
+		query.Set(PostgresSSLMode, "disable")
+	}
+	for key, values := range params {
+		query[key] = values
+	}
+	u := url.URL{Scheme: "postgres", Host: addr, Path: "/" + dbname, RawQuery: query.Encode()}
+	if password != "" {
+		u.User = url.UserPassword(user, password)
+	} else if user != "" {
+		u.User = url.User(user)
+	}
+	dsn := u.String()
+	return dsn, CheckDSN("postgres", dsn)
+}
+
+// CheckDSN reports the problems of a real driver DSN which txdb knows
+// about: malformed values of the parameters above, a mysql DSN without
+// multiStatements=true and a database name without the "_test" suffix,
+// see WithAllowedDSNPattern. Malformed parameters are also reported when
+// the driver is registered.
+func CheckDSN(drv, dsn string) error {
+	if err := checkDSNParams(drv, dsn); err != nil {
+		return err
+	}
+	for _, issue := range dsnIssues {
+		if issue.driver == drv && issue.affects(dsn) {
+			return fmt.Errorf("txdb: %s", issue.msg)
+		}
+	}
+	if !defaultAllowedDSNPattern.MatchString(dsnDatabase(drv, dsn)) {
+		return fmt.Errorf("txdb: database name of %s DSN does not end with _test", drv)
+	}
+	return nil
+}
+
+// dsnDatabase returns the name of the database a real driver DSN points
+// to: the dbname field of a postgres key=value DSN, the path of a URL DSN,
+// or else the part after the last slash, up to the parameters, as the
+// mysql driver parses it. It is empty if the DSN names no database.
+func dsnDatabase(drv, dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimPrefix(u.Path, "/")
+	}
+	if drv == "postgres" || drv == "pgx" {
+		return postgresParam(dsn, "dbname")
+	}
+	i := strings.LastIndex(dsn, "/")
+	if i < 0 {
+		return ""
+	}
+	name, _, _ := strings.Cut(dsn[i+1:], "?")
+	return name
+}
+
+// checkDSNParams checks the values of the known parameters of the dsn.
+func checkDSNParams(drv, dsn string) error {
+	switch drv {
+	case "mysql":
+		for _, name := range []string{MySQLMultiStatements, MySQLParseTime} {
+			if value := dsnParam(dsn, name); value != "" {
+				if _, err := strconv.ParseBool(value); err != nil {
+					return fmt.Errorf("txdb: mysql DSN parameter %s must be a boolean, got %q", name, value)
+				}
+			}
+		}
+	case "postgres", "pgx":
+		if mode := postgresParam(dsn, PostgresSSLMode); mode != "" && !slices.Contains(postgresSSLModes, mode) {
+			return fmt.Errorf("txdb: postgres DSN parameter %s must be one of %s, got %q", PostgresSSLMode, strings.Join(postgresSSLModes, ", "), mode)
+		}
+	}
+	return nil
+}
+
+// postgresParam returns a parameter of a postgres DSN, either in the URL
+// or in the key=value form.
+func postgresParam(dsn, name string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return dsnParam(dsn, name)
+	}
+	for _, field := range strings.Fields(dsn) {
+		if key, value, ok := strings.Cut(field, "="); ok && key == name {
+			return strings.Trim(value, "'")
+		}
+	}
+	return ""
+}
+
+func isLocalAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,124 @@
+package txdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithReaper enables a background reaper which rolls back and removes
+// connections not used for longer than idle, so that DSNs abandoned by
+// crashed subtests do not hold their transaction and locks for the rest
+// of the run. The optional onReap hook is called for every reaped
+// connection with the error of its rollback, if any.
+//
+// Further use of a reaped connection fails with an error, its
+// transaction is not silently started over.
+func WithReaper(idle time.Duration, onReap func(info ConnInfo, err error)) Option {
+	return func(cfg *Config) error {
+		if idle <= 0 {
+			return fmt.Errorf("txdb: reaper idle duration must be positive, got %s", idle)
+		}
+		cfg.reapIdle = idle
+		cfg.onReap = onReap
+		return nil
+	}
+}
+
+func (d *TxDriver) startReaper(idle time.Duration) {
+	// d must be locked before call
+	stop := make(chan struct{})
+	d.stopReaper = func() { close(stop) }
+	go func() {
+		ticker := time.NewTicker(idle / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				d.reap()
+			}
+		}
+	}()
+}
+
+// touch marks the connection as used now. Streamed rows are read without
+// the connection locked, so it is stored atomically.
+func (c *conn) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+// idle reports whether the connection was not used for longer than the
+// reaper allows.
+func (c *conn) idle() bool {
+	return c.reapIdle > 0 && time.Since(time.Unix(0, c.lastUsed.Load())) > c.reapIdle
+}
+
+type reapedConn struct {
+	info   ConnInfo
+	err    error
+	onReap func(ConnInfo, error)
+}
+
+// reap rolls back and removes idle connections. It never waits for a
+// connection while the driver is locked, a connection locked meanwhile is
+// in use, thus not idle, so opening and closing others is not blocked by a
+// long statement.
+func (d *TxDriver) reap() {
+	d.Lock()
+	candidates := make(map[string]*conn, len(d.conns))
+	for dsn, c := range d.conns {
+		if c.idle() {
+			candidates[dsn] = c
+		}
+	}
+	d.Unlock()
+
+	var reaped []reapedConn
+	for dsn, c := range candidates {
+		if r, ok := d.reapConn(dsn, c); ok {
+			reaped = append(reaped, r)
+		}
+	}
+	for _, r := range reaped {
+		if r.onReap != nil {
+			r.onReap(r.info, r.err)
+		}
+	}
+}
+
+// reapConn reaps the connection, unless it was used or removed since it
+// was found idle.
+func (d *TxDriver) reapConn(dsn string, c *conn) (r reapedConn, ok bool) {
+	d.Lock()
+	defer d.Unlock()
+	if d.conns[dsn] != c {
+		return r, false // closed, unregistered or replaced meanwhile
+	}
+	if !c.TryLock() {
+		return r, false // in use
+	}
+	defer c.Unlock()
+	if !c.idle() {
+		return r, false
+	}
+	r = reapedConn{info: c.info(), onReap: c.onReap}
+	c.expireRows()
+	if c.tx != nil {
+		c.resetSession()
+		c.logf("ROLLBACK (reaped)")
+		r.err = c.tx.Rollback()
+		c.cancel()
+		c.tx = nil
+		c.releaseRoot()
+	}
+	if err := c.cleanup(); err != nil && r.err == nil {
+		r.err = err
+	}
+	c.reaped = withKind(fmt.Errorf("txdb: connection %q was reaped after being idle for more than %s", dsn, c.reapIdle), ErrConnClosed)
+	if err := d.deleteConn(dsn); err != nil && r.err == nil {
+		r.err = err
+	}
+	d.setCloseError(dsn, r.err)
+	return r, true
+}
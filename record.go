@@ -0,0 +1,217 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Record is a statement successfully executed through a txdb connection,
+// or a transaction begun through it, captured when recording is enabled
+// with WithRecording.
+type Record struct {
+	// Query is the SQL text of the statement, empty for transactions.
+	Query string
+	// Args are the arguments the statement was executed with.
+	Args []interface{}
+	// RowsAffected is the number of rows affected by the statement, when
+	// enabled with WithRowsAffectedRecording, otherwise it is -1.
+	RowsAffected int64
+	// RolledBack is set once the nested transaction, within which the
+	// statement was executed, is rolled back.
+	RolledBack bool
+	// TxOptions are the options a transaction was begun with. They are
+	// recorded, even though savepoints can not honor them, so tests can
+	// assert e.g. the isolation level requested by the application.
+	// It is nil for statements.
+	TxOptions *sql.TxOptions
+	// Results describe the result sets returned by a query, so tests can
+	// assert them without querying the database again. It is nil for
+	// other statements and for streamed queries, see WithStreaming.
+	Results []ResultSet
+}
+
+// ResultSet describes a result set returned by a recorded query.
+type ResultSet struct {
+	Columns []string
+	Rows    int
+}
+
+// WithRecording enables recording of statements executed on the
+// connection. Recorded statements can be retrieved with [TxDriver.Records].
+// Individual statements may be excluded with [NoRecord].
+func WithRecording() Option {
+	return func(cfg *Config) error {
+		cfg.recording = true
+		return nil
+	}
+}
+
+// WithRowsAffectedRecording enables recording, see WithRecording, and
+// makes it record the number of rows affected by executed statements.
+func WithRowsAffectedRecording() Option {
+	return func(cfg *Config) error {
+		cfg.recording = true
+		cfg.recordAffected = true
+		return nil
+	}
+}
+
+// Records returns statements recorded so far on the connection opened
+// with the given dsn identifier, in the order they were executed.
+func (d *TxDriver) Records(dsn string) []Record {
+	d.Lock()
+	c, ok := d.conns[dsn]
+	d.Unlock()
+	if !ok {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return append([]Record(nil), c.records...)
+}
+
+// record notes a successfully executed statement, res is nil for queries.
+func (c *conn) record(ctx context.Context, query string, args []interface{}, res driver.Result) {
+	// c must be locked before call
+	c.lastQuery = query
+	c.touch()
+	if len(args) > 0 {
+		c.logf("%s %v", query, args)
+	} else {
+		c.logf("%s", query)
+	}
+	c.emit(Event{Type: StmtExecuted, Query: query, Args: args})
+	// direct statements are not part of the transaction, so never recorded
+	if !c.recording || isNoRecord(ctx) || isDirect(ctx) {
+		return
+	}
+	r := Record{Query: query, Args: args, RowsAffected: -1}
+	if c.recordAffected && res != nil {
+		if n, err := res.RowsAffected(); err == nil {
+			r.RowsAffected = n
+		}
+	}
+	c.records = append(c.records, r)
+}
+
+// recordRows notes a successfully executed query along with the
+// description of its buffered result sets.
+func (c *conn) recordRows(ctx context.Context, query string, args []interface{}, set *rowSets) {
+	// c must be locked before call
+	n := len(c.records)
+	c.record(ctx, query, args, nil)
+	if len(c.records) == n {
+		return // not recorded
+	}
+	results := make([]ResultSet, len(set.sets))
+	for i, rs := range set.sets {
+		results[i] = ResultSet{Columns: rs.cols, Rows: rs.len}
+	}
+	c.records[n].Results = results
+}
+
+func (c *conn) recordBegin(ctx context.Context, opts driver.TxOptions) {
+	c.Lock()
+	defer c.Unlock()
+	if !c.recording || isNoRecord(ctx) {
+		return
+	}
+	c.records = append(c.records, Record{
+		RowsAffected: -1,
+		TxOptions: &sql.TxOptions{
+			Isolation: sql.IsolationLevel(opts.Isolation),
+			ReadOnly:  opts.ReadOnly,
+		},
+	})
+}
+
+func (c *conn) markSavePoint(id string) {
+	// c must be locked before call
+	if !c.recording {
+		return
+	}
+	if c.marks == nil {
+		c.marks = make(map[string]int)
+	}
+	c.marks[id] = len(c.records)
+}
+
+// rollbackRecords marks records starting from the given index as rolled back.
+func (c *conn) rollbackRecords(from int) {
+	// c must be locked before call
+	for i := from; i < len(c.records); i++ {
+		c.records[i].RolledBack = true
+	}
+}
+
+// Fork opens a new connection with the dstDSN identifier and replays in its
+// transaction all statements recorded on the srcDSN connection, except
+// the rolled back ones. It is a cheap way to branch a prepared state into
+// multiple independent scenarios. The source connection must have
+// recording enabled, see WithRecording.
+//
+// The forked connection stays open until it is opened with dstDSN and
+// closed again, like any other connection. Note, the source transaction is
+// still open, so replaying writes of rows or unique keys locked by it
+// blocks until it ends. Forking works best for state which is not
+// shared between sessions, like temporary tables.
+func (d *TxDriver) Fork(srcDSN, dstDSN string) error {
+	d.Lock()
+	src, ok := d.conns[srcDSN]
+	_, exists := d.conns[dstDSN]
+	d.Unlock()
+	if !ok {
+		return fmt.Errorf("txdb: connection %q is not open", srcDSN)
+	}
+	if exists {
+		return fmt.Errorf("txdb: can not fork into %q, connection is already open", dstDSN)
+	}
+
+	src.Lock()
+	recording, records := src.recording, append([]Record(nil), src.records...)
+	src.Unlock()
+	if !recording {
+		return fmt.Errorf("txdb: connection %q does not record statements, see WithRecording", srcDSN)
+	}
+
+	dc, err := d.Open(dstDSN)
+	if err != nil {
+		return err
+	}
+	c := dc.(*conn)
+	if err := c.replay(records); err != nil {
+		c.Close()
+		return err
+	}
+
+	d.Lock()
+	c.Lock()
+	c.opened-- // keep it until opened again by the caller
+	c.Unlock()
+	d.Unlock()
+	return nil
+}
+
+func (c *conn) replay(records []Record) error {
+	c.Lock()
+	defer c.Unlock()
+
+	tx, err := c.beginOnce()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.RolledBack || r.TxOptions != nil {
+			continue
+		}
+		res, err := tx.Exec(r.Query, r.Args...)
+		if err != nil {
+			return fmt.Errorf("txdb: failed to replay %q: %w", r.Query, err)
+		}
+		c.record(context.Background(), r.Query, r.Args, res)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+var (
+	registerMu sync.Mutex
+	// txdb drivers by the name they are registered with
+	registered = make(map[string]*TxDriver)
+	// unregistered drivers by name, reused by the next registration of
+	// the name, since database/sql can not unregister a driver
+	unregistered = make(map[string]*TxDriver)
+)
+
+// register registers d under name, or reuses the driver unregistered
+// with the name before.
+func register(name string, d *TxDriver) *TxDriver {
+	// registerMu must be locked before call
+	prev, ok := unregistered[name]
+	if !ok {
+		sql.Register(name, d)
+		d.name = name
+		registered[name] = d
+		return d
+	}
+	delete(unregistered, name)
+	registered[name] = prev
+
+	prev.Lock()
+	defer prev.Unlock()
+	prev.conns, prev.options, prev.aliases = d.conns, d.options, d.aliases
+	prev.drv, prev.dsn, prev.layer, prev.err = d.drv, d.dsn, d.layer, d.err
+	prev.pool, prev.connector = d.pool, d.connector
+	prev.bootstrapped, prev.closeErrs, prev.latencies, prev.version, prev.versionProbed = false, nil, nil, ServerVersion{}, false
+	return prev
+}
+
+// TryRegister registers a txdb sql driver like [Register], but instead of
+// panicking when the name is already taken, it reuses the registered
+// driver if it is a txdb driver for the same drv and dsn, and returns an
+// error otherwise. This makes it safe to register the same driver from
+// several test packages or init functions. Note, options of a reused
+// registration are those of the first one.
+func TryRegister(name, drv, dsn string, options ...Option) (*TxDriver, error) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	d, ok := registered[name]
+	if !ok {
+		_, gone := unregistered[name]
+		ok = !gone && slices.Contains(sql.Drivers(), name) // not a txdb driver
+	}
+	if ok {
+		if d == nil || d.drv != drv || d.dsn != dsn {
+			return nil, fmt.Errorf("txdb: sql driver %q is already registered with a different driver or dsn", name)
+		}
+		return d, nil
+	}
+	return register(name, newDriver(drv, dsn, options)), nil
+}
+
+// Unregister rolls back all open transactions of the txdb driver
+// registered under name, closes its real database and frees the name, so
+// it can be registered again. Long running test binaries registering
+// drivers dynamically so do not leak real connections. Connections still
+// held by a [database/sql.DB] fail with an error afterwards.
+func Unregister(name string) error {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	d, ok := registered[name]
+	if !ok {
+		return fmt.Errorf("txdb: no txdb driver is registered as %q", name)
+	}
+	delete(registered, name)
+	reason := withKind(fmt.Errorf("txdb: driver %q was unregistered", name), ErrConnClosed)
+	err := d.shutdown(reason)
+	d.Lock()
+	d.err = reason
+	d.Unlock()
+	unregistered[name] = d
+	return err
+}
+
+// Close rolls back the transactions of all connections of the driver and
+// closes its real database, no matter whether the connections were closed
+// by their [database/sql.DB] handles. It is meant to be called once all
+// tests are done, e.g. from TestMain, as a deterministic teardown point.
+// Handles still open fail with an error afterwards, while the driver stays
+// registered and can be opened again.
+func (d *TxDriver) Close() error {
+	return d.shutdown(withKind(errors.New("txdb: driver was closed"), ErrConnClosed))
+}
+
+// CloseAll closes every registered txdb driver, like [TxDriver.Close], and
+// returns their errors joined. It is meant for the teardown in TestMain,
+// so connections do not linger until the process exits. Drivers wrapping
+// other txdb drivers are closed first. Closing stops once ctx is done,
+// which is reported as well.
+func CloseAll(ctx context.Context) error {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	type named struct {
+		name string
+		d    *TxDriver
+	}
+	var drivers []named
+	for name, d := range registered {
+		drivers = append(drivers, named{name, d})
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		if drivers[i].d.layer != drivers[j].d.layer {
+			return drivers[i].d.layer > drivers[j].d.layer
+		}
+		return drivers[i].name < drivers[j].name
+	})
+
+	var errs []error
+	for _, n := range drivers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("txdb: closing drivers was interrupted before %q: %w", n.name, err))
+			break
+		}
+		if err := n.d.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("txdb: failed to close driver %q: %w", n.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// registeredDriver returns the txdb driver registered under name.
+func registeredDriver(name string) (*TxDriver, bool) {
+	// registerMu must be locked before call
+	d, ok := registered[name]
+	return d, ok
+}
+
+// shutdown rolls back and removes all connections, which fail with the
+// reason error afterwards, and closes the real database.
+func (d *TxDriver) shutdown(reason error) error {
+	d.Lock()
+	defer d.Unlock()
+
+	var errs []error
+	for dsn, c := range d.conns {
+		c.Lock()
+		c.expireRows()
+		if c.tx != nil {
+			c.resetSession()
+			c.logf("ROLLBACK (%s)", reason)
+			if err := c.tx.Rollback(); err != nil {
+				errs = append(errs, fmt.Errorf("txdb: failed to rollback %q: %w", dsn, err))
+			}
+			c.cancel()
+			c.tx = nil
+			c.releaseRoot()
+		}
+		if err := c.cleanup(); err != nil {
+			errs = append(errs, err)
+		}
+		c.reaped = reason
+		c.Unlock()
+		if err := d.deleteConn(dsn); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := d.closeDB(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
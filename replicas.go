@@ -0,0 +1,24 @@
+package txdb
+
+// RegisterWithReplicas registers a txdb sql driver like [Register], where
+// connections opened with any of the replicas dsn identifiers resolve to
+// the connection opened with the primary dsn identifier. Code using a
+// read/write split router can so be tested in full isolation, while still
+// exercising its routing logic, since reads from a replica see the writes
+// made through the primary within the same transaction:
+//
+//	txdb.RegisterWithReplicas("txdb", "mysql", "root@/txdb_test", "primary", []string{"replica1", "replica2"})
+//	primary, _ := sql.Open("txdb", "primary")
+//	replica, _ := sql.Open("txdb", "replica1")
+func RegisterWithReplicas(name, drv, dsn, primary string, replicas []string, options ...Option) *TxDriver {
+	aliases := make(map[string]string, len(replicas))
+	for _, replica := range replicas {
+		aliases[replica] = primary
+	}
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	d := newDriver(drv, dsn, options)
+	d.aliases = aliases
+	return register(name, d)
+}
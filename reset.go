@@ -0,0 +1,40 @@
+package txdb
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// resetPoint is the savepoint created right after the root BEGIN.
+const resetPoint = "txdb_reset"
+
+// WithResetPoint creates a savepoint right after the root transaction has
+// begun, which Reset rolls back to. It requires savepoints.
+func WithResetPoint() Option {
+	return func(cfg *Config) error {
+		cfg.resetPoint = true
+		return nil
+	}
+}
+
+// Reset rolls the transaction of the txdb connection behind db back to
+// its reset point, see WithResetPoint, restoring the state it had when it
+// begun, while the connection and db stay open. Table driven tests can so
+// reuse one handle across many cases. Nested transactions still open are
+// discarded and statements prepared within the transaction are closed.
+func Reset(db *sql.DB) error {
+	return withConn(db, func(c *conn) error {
+		if !c.resetPoint || c.SavePoint == nil {
+			return errors.New("txdb: reset requires a reset point, see WithResetPoint")
+		}
+		if c.tx == nil {
+			return nil // nothing to reset yet
+		}
+		if err := c.execSavePoint(c.base(), c.tx, c.SavePoint.Rollback(resetPoint)); err != nil {
+			return err
+		}
+		c.logf("RESET")
+		c.discardNested("the reset")
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package txdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// writeTablePattern captures the table written by a DML statement.
+var writeTablePattern = regexp.MustCompile("(?is)^\\s*(?:/\\*.*?\\*/\\s*)*(?:INSERT\\s+(?:IGNORE\\s+)?INTO|REPLACE\\s+INTO|UPDATE|DELETE\\s+FROM)\\s+([\\w.`\"]+)")
+
+// WithRestoreOnRollbackFailure enables a best effort recovery, when the
+// final rollback on Close fails, for example because the connection was
+// lost. Then a new connection to the real database is opened and all
+// tables written during the test are truncated, so one bad teardown does
+// not contaminate subsequent tests. The tables are known from recorded
+// statements, so the option enables recording too, see WithRecording.
+// Note, truncating also removes rows the tables contained before the test.
+func WithRestoreOnRollbackFailure() Option {
+	return func(cfg *Config) error {
+		cfg.recording = true
+		cfg.restoreOnFailure = true
+		return nil
+	}
+}
+
+// restore truncates tables written by recorded statements and returns
+// rollbackErr annotated with the outcome.
+func (c *conn) restore(rollbackErr error) error {
+	tables := writtenTables(c.records)
+	db, err := c.drv.openReal()
+	if err != nil {
+		return fmt.Errorf("txdb: rollback failed: %w, restore failed: %v", rollbackErr, err)
+	}
+	defer c.drv.closeReal(db)
+
+	var failed []string
+	for _, table := range tables {
+		if _, err := db.Exec("TRUNCATE TABLE " + table); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", table, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("txdb: rollback failed: %w, failed to truncate %s", rollbackErr, strings.Join(failed, ", "))
+	}
+	return fmt.Errorf("txdb: rollback failed: %w, truncated tables %v", rollbackErr, tables)
+}
+
+func writtenTables(records []Record) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, r := range records {
+		m := writeTablePattern.FindStringSubmatch(r.Query)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		tables = append(tables, m[1])
+	}
+	sort.Strings(tables)
+	return tables
+}
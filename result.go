@@ -0,0 +1,42 @@
+package txdb
+
+import "database/sql/driver"
+
+// WithNormalizedResults makes LastInsertId and RowsAffected of statement
+// results return 0 without an error, where the wrapped driver returns an
+// error, e.g. LastInsertId with postgres. Shared test helpers then do not
+// need per driver branches.
+func WithNormalizedResults() Option {
+	return func(cfg *Config) error {
+		cfg.normalizeResults = true
+		return nil
+	}
+}
+
+type normalizedResult struct {
+	driver.Result
+}
+
+func (r normalizedResult) LastInsertId() (int64, error) {
+	id, err := r.Result.LastInsertId()
+	if err != nil {
+		return 0, nil
+	}
+	return id, nil
+}
+
+func (r normalizedResult) RowsAffected() (int64, error) {
+	n, err := r.Result.RowsAffected()
+	if err != nil {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// result applies the configured normalization to res.
+func (c *conn) result(res driver.Result) driver.Result {
+	if !c.normalizeResults || res == nil {
+		return res
+	}
+	return normalizedResult{res}
+}
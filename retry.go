@@ -0,0 +1,82 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// transientMessages are fragments of errors reported by databases which
+// are out of connections or not ready to accept them yet.
+var transientMessages = []string{
+	"too many connections",                 // mysql 1040, postgres 53300
+	"the database system is starting up",   // postgres 57P03
+	"the database system is shutting down", // postgres 57P03
+	"remaining connection slots are reserved",
+	"connection refused",
+}
+
+// WithBeginRetry retries opening the real database and beginning the root
+// transaction up to the given number of attempts, waiting delay between
+// them, if it fails with a transient error, like too many connections or
+// a database still starting up. This avoids flaky failures of the first
+// test in CI, where the database is started alongside the tests.
+func WithBeginRetry(attempts int, delay time.Duration) Option {
+	return func(cfg *Config) error {
+		if attempts < 1 {
+			return fmt.Errorf("txdb: begin retry attempts must be positive, got %d", attempts)
+		}
+		cfg.beginAttempts, cfg.beginDelay = attempts, delay
+		return nil
+	}
+}
+
+// beginRoot begins the root transaction, retrying on transient errors.
+func (c *conn) beginRoot(ctx context.Context) (tx *sql.Tx, err error) {
+	// c must be locked before call
+	var sc *sql.Conn
+	err = c.retry(ctx, "BEGIN", func() (err error) {
+		sc, tx, err = c.beginPinned(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("txdb: failed to begin transaction of %q: %w", c.dsn, err)
+	}
+	c.rootConn = sc
+	return tx, nil
+}
+
+// retry calls f until it succeeds, fails with an error which is not
+// transient, or the attempts set with WithBeginRetry are exhausted.
+func (c *conn) retry(ctx context.Context, what string, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= c.beginAttempts || !isTransient(err) {
+			return err
+		}
+		c.logf("%s failed with %s, retrying", what, err)
+		select {
+		case <-time.After(c.beginDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transientMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
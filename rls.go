@@ -0,0 +1,56 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// InSettings runs f within a nested transaction of db, see InSavePoint,
+// with the given postgres settings applied, like the app.tenant_id a row
+// level security policy reads with current_setting. Previous values are
+// restored once f returns, even when the nested transaction is committed,
+// so policies can be exercised for several tenants within one test:
+//
+//	txdb.InSettings(db, map[string]string{"app.tenant_id": "1"}, func(tx *sql.Tx) error {
+//		// only rows of tenant 1 are visible
+//	})
+//
+// The settings are applied with set_config local to the transaction,
+// combine it with WithRole to have the policies enforced.
+func InSettings(db *sql.DB, settings map[string]string, f func(tx *sql.Tx) error) error {
+	if drv, ok := db.Driver().(*TxDriver); ok && drv.drv != "postgres" && drv.drv != "pgx" {
+		return fmt.Errorf("txdb: settings are only supported with postgres, not %s", drv.drv)
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return InSavePoint(db, func(tx *sql.Tx) (err error) {
+		previous := make(map[string]string, len(names))
+		for _, name := range names {
+			var value sql.NullString
+			if err := tx.QueryRow("SELECT current_setting($1, true)", name).Scan(&value); err != nil {
+				return fmt.Errorf("txdb: failed to read setting %s: %w", name, err)
+			}
+			previous[name] = value.String
+		}
+		defer func() {
+			for _, name := range names {
+				if _, rerr := tx.Exec("SELECT set_config($1, $2, true)", name, previous[name]); rerr != nil && err == nil {
+					err = fmt.Errorf("txdb: failed to restore setting %s: %w", name, rerr)
+				}
+			}
+		}()
+
+		for _, name := range names {
+			if _, err := tx.Exec("SELECT set_config($1, $2, true)", name, settings[name]); err != nil {
+				return fmt.Errorf("txdb: failed to apply setting %s: %w", name, err)
+			}
+		}
+		return f(tx)
+	})
+}
@@ -0,0 +1,39 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// WithRole switches the role of the session running the transaction, so
+// row-level security and permission dependent code can be tested, e.g.
+// per dsn identifier with WithDSNOptions. With postgres it runs SET LOCAL
+// ROLE, which ends with the transaction. With mysql it activates the
+// role with SET ROLE, which is reset to the default roles before the
+// transaction is rolled back. The connecting user must be granted the
+// role.
+func WithRole(role string) Option {
+	return func(cfg *Config) error {
+		cfg.role = role
+		return nil
+	}
+}
+
+func (c *conn) setRole(tx *sql.Tx) error {
+	if c.role == "" {
+		return nil
+	}
+	var err error
+	switch c.drv.drv {
+	case "postgres", "pgx":
+		_, err = tx.Exec("SELECT set_config('role', $1, true)", c.role)
+	case "mysql":
+		_, err = tx.Exec("SET ROLE " + quoteString(c.role, true))
+	default:
+		return fmt.Errorf("txdb: role switching is not supported with %s", c.drv.drv)
+	}
+	if err != nil {
+		return fmt.Errorf("txdb: failed to switch to role %q: %w", c.role, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package txdb
+
+import "fmt"
+
+// Rollback rolls back the transaction of the connection opened with the
+// dsn identifier, without closing it, so the next statement begins a new
+// transaction on the same [database/sql.DB]. This resets the database
+// state between subtests sharing a handle held by a framework. Nested
+// transactions still open are discarded and statements prepared within
+// the transaction are closed.
+func (d *TxDriver) Rollback(dsn string) error {
+	d.Lock()
+	defer d.Unlock()
+	if primary, ok := d.aliases[dsn]; ok {
+		dsn = primary
+	}
+	c, ok := d.conns[dsn]
+	if !ok {
+		return fmt.Errorf("txdb: no connection is open with dsn %q", dsn)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return c.rollbackRoot()
+}
+
+// rollbackRoot rolls back the root transaction and resets the state of
+// nested transactions, so the next statement begins a new one.
+func (c *conn) rollbackRoot() (err error) {
+	// c must be locked before call
+	if c.tx != nil && c.lost == nil {
+		c.resetSession()
+		c.logf("ROLLBACK")
+		err = c.tx.Rollback()
+	}
+	c.cancel()
+	c.tx, c.lost = nil, nil
+	c.releaseRoot()
+	c.cancel, c.ctx = func() {}, stubCtx{}
+
+	c.discardNested("the transaction")
+	if err != nil {
+		c.cleanup()
+		return fmt.Errorf("txdb: failed to rollback %q: %w", c.dsn, err)
+	}
+	return c.cleanup()
+}
+
+// discardNested forgets all nested transactions and closes the statements
+// prepared within them, after they were rolled back by the given means.
+func (c *conn) discardNested(by string) {
+	// c must be locked before call
+	c.closePreparedSince(0, by)
+	c.rollbackRecords(0)
+	c.stack, c.ended, c.pending, c.marks, c.txBegan, c.readOnly = nil, nil, nil, nil, nil, ""
+	c.discards = c.saves
+	c.expireRows()
+}
+
+// discarded reports whether the savepoint with the given id belonged to a
+// root transaction rolled back by TxDriver.Rollback, or was discarded by
+// Reset.
+func (c *conn) discarded(id string) bool {
+	// c must be locked before call
+	seq, ok := c.savePointSeq(id)
+	return ok && seq <= c.discards
+}
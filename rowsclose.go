@@ -0,0 +1,56 @@
+package txdb
+
+import (
+	"fmt"
+)
+
+// RowsAfterClose defines what happens to rows still being iterated when
+// the transaction they were read in ends, because the connection was
+// closed, reaped, unregistered, rolled back with TxDriver.Rollback or
+// reset with Reset.
+type RowsAfterClose int
+
+const (
+	// ServeBufferedRows serves the remaining rows, which are buffered in
+	// memory, which is the default.
+	ServeBufferedRows RowsAfterClose = iota
+	// FailRows fails reading the remaining rows with an error naming the
+	// connection.
+	FailRows
+)
+
+// WithRowsAfterClose sets what happens to rows still being iterated when
+// the transaction they were read in ends. Streamed rows, see
+// WithStreaming, always fail, since they are not buffered.
+func WithRowsAfterClose(behavior RowsAfterClose) Option {
+	return func(cfg *Config) error {
+		cfg.rowsAfterClose = behavior
+		return nil
+	}
+}
+
+// expireRows marks rows read so far as read in an ended transaction.
+func (c *conn) expireRows() {
+	c.generation.Add(1)
+}
+
+// rowsGuard fails reading rows after the transaction they were read in
+// has ended.
+type rowsGuard struct {
+	conn       *conn
+	generation uint64
+}
+
+func newRowsGuard(c *conn) rowsGuard {
+	return rowsGuard{conn: c, generation: c.generation.Load()}
+}
+
+func (g rowsGuard) check(buffered bool) error {
+	if g.conn == nil || buffered && g.conn.rowsAfterClose == ServeBufferedRows {
+		return nil
+	}
+	if g.conn.generation.Load() != g.generation {
+		return withKind(fmt.Errorf("txdb: rows were read after the transaction of %q ended, see WithRowsAfterClose", g.conn.dsn), ErrTxClosed)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package txdb
+
+import "database/sql/driver"
+
+// RowStore stores the rows of a buffered result set, see WithRowStore.
+// Rows are appended while the result set is read from the database, with
+// the connection locked, and read back in order once it is complete. It
+// is not used concurrently.
+type RowStore interface {
+	// Append stores the next row, the store owns the values.
+	Append(row []driver.Value) error
+	// Row returns the i-th appended row, counting from 0.
+	Row(i int) ([]driver.Value, error)
+	// Close releases the rows, once the result set is closed.
+	Close() error
+}
+
+// WithRowStore sets the function creating the store of every buffered
+// result set of the given query, which holds rows in memory by default.
+// This allows to compress rows, or to spill large results to disk.
+func WithRowStore(newStore func(query string, columns []string) RowStore) Option {
+	return func(cfg *Config) error {
+		cfg.newRowStore = newStore
+		return nil
+	}
+}
+
+func (c *conn) rowStore(query string, columns []string) RowStore {
+	if c.newRowStore == nil {
+		return &memoryRowStore{}
+	}
+	return c.newRowStore(query, columns)
+}
+
+type memoryRowStore struct {
+	rows [][]driver.Value
+}
+
+func (s *memoryRowStore) Append(row []driver.Value) error {
+	s.rows = append(s.rows, row)
+	return nil
+}
+
+func (s *memoryRowStore) Row(i int) ([]driver.Value, error) {
+	return s.rows[i], nil
+}
+
+func (s *memoryRowStore) Close() error {
+	s.rows = nil
+	return nil
+}
@@ -0,0 +1,45 @@
+package txdb
+
+import "fmt"
+
+// disabledSavePoint is the id of a nested transaction begun while
+// savepoints are disabled, its Commit and Rollback are no-ops, which are
+// reported with WithSavePointlessCheck.
+const disabledSavePoint = "-"
+
+// SavePointlessError reports Commit or Rollback of a nested transaction,
+// which had no effect, since it was begun while savepoints are disabled,
+// see WithSavePointlessCheck.
+type SavePointlessError struct {
+	DSN string
+	Op  string // "Commit" or "Rollback"
+}
+
+func (e *SavePointlessError) Error() string {
+	return fmt.Sprintf("txdb: %s of a nested transaction of %q had no effect, since savepoints are disabled", e.Op, e.DSN)
+}
+
+// WithSavePointlessCheck detects Commit and Rollback of nested
+// transactions begun while savepoints are disabled, see SavePointOption,
+// which silently succeed otherwise, so the transaction boundaries of the
+// code under test are not exercised. If hook is nil, they fail with a
+// *SavePointlessError, otherwise hook is called with it, and they succeed.
+// Transactions begun with SkipSavePoint are not reported.
+func WithSavePointlessCheck(hook func(err *SavePointlessError)) Option {
+	return func(cfg *Config) error {
+		cfg.savePointlessCheck, cfg.savePointlessHook = true, hook
+		return nil
+	}
+}
+
+func (c *conn) savePointless(op string) error {
+	if !c.savePointlessCheck {
+		return nil
+	}
+	err := &SavePointlessError{DSN: c.dsn, Op: op}
+	if c.savePointlessHook != nil {
+		c.savePointlessHook(err)
+		return nil
+	}
+	return err
+}
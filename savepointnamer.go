@@ -0,0 +1,48 @@
+package txdb
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SavePointNamer returns the name of the savepoint of the seq-th nested
+// transaction on the connection opened with the dsn identifier. Sequence
+// numbers start at 1 and grow for the life of the connection. Names must
+// be unique within the connection and valid identifiers of the database,
+// unless the SavePoint syntax quotes them, see BacktickSavePoint.
+type SavePointNamer func(dsn string, seq uint) string
+
+// WithSavePointNamer sets how savepoints of nested transactions are named,
+// so they do not collide with savepoints the application creates itself,
+// and logs or traces carry names identifying the test. By default they
+// are named tx_1, tx_2 and so on. Note, txdb drivers wrapping one another
+// must name their savepoints differently.
+func WithSavePointNamer(namer SavePointNamer) Option {
+	return func(cfg *Config) error {
+		cfg.savePointNamer = namer
+		return nil
+	}
+}
+
+// SavePointPrefix returns a SavePointNamer naming savepoints with the
+// prefix followed by the sequence number.
+func SavePointPrefix(prefix string) SavePointNamer {
+	return func(_ string, seq uint) string {
+		return prefix + strconv.FormatUint(uint64(seq), 10)
+	}
+}
+
+// namedSavePoint names the savepoint with the given sequence number with
+// the SavePointNamer and remembers the number of the name.
+func (c *conn) namedSavePoint(seq uint) (string, error) {
+	// c must be locked before call
+	id := c.savePointNamer(c.dsn, seq)
+	if _, taken := c.savePointSeqs[id]; taken || id == "" || id == noSavePoint || id == disabledSavePoint {
+		return "", fmt.Errorf("txdb: savepoint name %q is empty, reserved or already used on %q, see WithSavePointNamer", id, c.dsn)
+	}
+	if c.savePointSeqs == nil {
+		c.savePointSeqs = make(map[string]uint)
+	}
+	c.savePointSeqs[id] = seq
+	return id, nil
+}
@@ -0,0 +1,40 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// WithSeed executes the given statements right after the root transaction
+// has begun, for every connection, so fixtures shared by the tests need
+// not be inserted by each of them. Unlike WithBootstrap, the seed is
+// rolled back with the transaction. Seeds run before the reset point, see
+// WithResetPoint, so Reset restores the seeded state.
+func WithSeed(statements ...string) Option {
+	return WithSeedFunc(func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return fmt.Errorf("%q: %w", statement, err)
+			}
+		}
+		return nil
+	})
+}
+
+// WithSeedFunc calls f with the root transaction right after it has
+// begun, like WithSeed.
+func WithSeedFunc(f func(tx *sql.Tx) error) Option {
+	return func(cfg *Config) error {
+		cfg.seeds = append(cfg.seeds, f)
+		return nil
+	}
+}
+
+func (c *conn) seed(tx *sql.Tx) error {
+	for _, f := range c.seeds {
+		if err := f(tx); err != nil {
+			return fmt.Errorf("txdb: seed failed: %w", err)
+		}
+	}
+	return nil
+}
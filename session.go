@@ -0,0 +1,60 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// WithSQLMode sets the MySQL sql_mode of the session running the
+// transaction, for example "STRICT_ALL_TABLES,ONLY_FULL_GROUP_BY", so tests
+// run with the same strictness as production regardless of the server
+// defaults. The previous mode is restored before the transaction is
+// rolled back.
+func WithSQLMode(mode string) Option {
+	return func(cfg *Config) error {
+		cfg.sqlMode = &mode
+		return nil
+	}
+}
+
+// initSession prepares the session of a freshly begun root transaction.
+func (c *conn) initSession(tx *sql.Tx) error {
+	if err := c.setLabel(tx); err != nil {
+		return err
+	}
+	if err := c.setRole(tx); err != nil {
+		return err
+	}
+	if c.sqlMode != nil {
+		if c.drv.drv != "mysql" {
+			return fmt.Errorf("txdb: sql_mode is only supported with mysql, not %s", c.drv.drv)
+		}
+		if err := tx.QueryRow("SELECT @@SESSION.sql_mode").Scan(&c.sqlModeBefore); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("SET SESSION sql_mode = ?", *c.sqlMode); err != nil {
+			return err
+		}
+	}
+	if err := c.seed(tx); err != nil {
+		return err
+	}
+	if c.resetPoint && c.SavePoint != nil {
+		if err := c.execSavePoint(c.base(), tx, c.SavePoint.Create(resetPoint)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetSession restores session settings, which are not transactional,
+// before the root transaction is rolled back and its session reused.
+func (c *conn) resetSession() {
+	// c must be locked before call
+	if c.sqlMode != nil {
+		c.tx.Exec("SET SESSION sql_mode = ?", c.sqlModeBefore)
+	}
+	if c.role != "" && c.drv.drv == "mysql" {
+		c.tx.Exec("SET ROLE DEFAULT")
+	}
+}
@@ -0,0 +1,67 @@
+package txdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Shared is a reference counted txdb driver registration, which test
+// packages share through an exported variable of a common helper package:
+//
+//	var DB = &txdb.Shared{Name: "txdb", Driver: "mysql", DSN: "root@/txdb_test"}
+//
+//	func TestMain(m *testing.M) {
+//		if _, err := testutil.DB.Acquire(); err != nil {
+//			log.Fatal(err)
+//		}
+//		code := m.Run()
+//		testutil.DB.Release()
+//		os.Exit(code)
+//	}
+//
+// The driver is registered by the first Acquire and unregistered, which
+// closes the real database, by the last Release.
+type Shared struct {
+	Name    string
+	Driver  string
+	DSN     string
+	Options []Option
+
+	mu   sync.Mutex
+	refs int
+	drv  *TxDriver
+}
+
+// Acquire registers the shared driver, unless it is already, and takes a
+// reference to it, which must be given back with Release.
+func (s *Shared) Acquire() (*TxDriver, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs == 0 {
+		d, err := TryRegister(s.Name, s.Driver, s.DSN, s.Options...)
+		if err != nil {
+			return nil, err
+		}
+		s.drv = d
+	}
+	s.refs++
+	return s.drv, nil
+}
+
+// Release gives back a reference taken by Acquire. The last one
+// unregisters the shared driver, rolling back all its transactions.
+func (s *Shared) Release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs == 0 {
+		return fmt.Errorf("txdb: shared driver %q is released more times than acquired", s.Name)
+	}
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	s.drv = nil
+	return Unregister(s.Name)
+}
@@ -0,0 +1,72 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+var sessionVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// SnapshotSession reads the given session variables of the txdb connection
+// behind db and returns a function restoring them, so subtests tweaking
+// the session, like the search_path or time zone, do not leak it into
+// later subtests sharing the transaction:
+//
+//	restore, err := txdb.SnapshotSession(db)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	t.Cleanup(func() { restore() })
+//
+// Without variables, search_path and TimeZone are taken on postgres, and
+// sql_mode and time_zone on mysql.
+func SnapshotSession(db *sql.DB, vars ...string) (restore func() error, err error) {
+	var drv string
+	if d, ok := db.Driver().(*TxDriver); ok {
+		drv = d.drv
+	}
+	postgres := drv == "postgres" || drv == "pgx"
+	if !postgres && drv != "mysql" {
+		return nil, fmt.Errorf("txdb: session snapshots are only supported with postgres and mysql, not %s", drv)
+	}
+	if len(vars) == 0 {
+		vars = []string{"sql_mode", "time_zone"}
+		if postgres {
+			vars = []string{"search_path", "TimeZone"}
+		}
+	}
+
+	values := make([]sql.NullString, len(vars))
+	for i, name := range vars {
+		if !sessionVarPattern.MatchString(name) {
+			return nil, fmt.Errorf("txdb: invalid session variable name %q", name)
+		}
+		query := "SELECT @@SESSION." + name
+		var args []interface{}
+		if postgres {
+			query, args = "SELECT current_setting($1)", []interface{}{name}
+		}
+		if err := db.QueryRow(query, args...).Scan(&values[i]); err != nil {
+			return nil, fmt.Errorf("txdb: failed to read session variable %s: %w", name, err)
+		}
+	}
+
+	return func() error {
+		for i, name := range vars {
+			var err error
+			switch {
+			case postgres:
+				_, err = db.Exec("SELECT set_config($1, $2, false)", name, values[i].String)
+			case values[i].Valid:
+				_, err = db.Exec("SET SESSION "+name+" = ?", values[i].String)
+			default:
+				_, err = db.Exec("SET SESSION " + name + " = DEFAULT")
+			}
+			if err != nil {
+				return fmt.Errorf("txdb: failed to restore session variable %s: %w", name, err)
+			}
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,154 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Stats contains statistics of a single txdb connection.
+type Stats struct {
+	// SavePointDepth is the number of currently open savepoints,
+	// in other words how deeply nested transactions are right now.
+	SavePointDepth int
+	// MaxSavePointDepth is the high-water mark of SavePointDepth.
+	MaxSavePointDepth int
+	// Locks is the number of times the connection was locked, which
+	// happens for every statement, since they are serialized.
+	Locks int64
+	// LockWait is the total time spent waiting for the connection, while
+	// it was in use by another goroutine.
+	LockWait time.Duration
+	// MaxLockWait is the longest single wait for the connection.
+	MaxLockWait time.Duration
+}
+
+// Stats returns statistics of the connection opened with the given dsn
+// identifier. The boolean reports whether such connection is currently
+// open.
+func (d *TxDriver) Stats(dsn string) (Stats, bool) {
+	d.Lock()
+	c, ok := d.conns[dsn]
+	d.Unlock()
+	if !ok {
+		return Stats{}, false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return Stats{
+		SavePointDepth:    len(c.stack),
+		MaxSavePointDepth: c.maxDepth,
+		Locks:             c.locks,
+		LockWait:          c.waited,
+		MaxLockWait:       c.maxWait,
+	}, true
+}
+
+// ConnInfo describes a connection tracked by the driver.
+type ConnInfo struct {
+	// DSN is the identifier the connection was opened with.
+	DSN string
+	// Opened is the number of times the connection is currently opened.
+	Opened int
+	// Age is the time elapsed since the connection was first opened.
+	Age time.Duration
+	// LastQuery is the last statement successfully executed on the connection.
+	LastQuery string
+}
+
+// Conns returns all currently open connections sorted by DSN. It is
+// useful in a suite teardown to find *sql.DB handles left open.
+func (d *TxDriver) Conns() []ConnInfo {
+	d.Lock()
+	defer d.Unlock()
+
+	infos := make([]ConnInfo, 0, len(d.conns))
+	for _, c := range d.conns {
+		c.Lock()
+		infos = append(infos, c.info())
+		c.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].DSN < infos[j].DSN
+	})
+	return infos
+}
+
+func (c *conn) info() ConnInfo {
+	// c and its driver must be locked before call
+	return ConnInfo{
+		DSN:       c.dsn,
+		Opened:    int(c.opened),
+		Age:       time.Since(c.created),
+		LastQuery: c.lastQuery,
+	}
+}
+
+// LastCloseError returns the error of the last final close of the
+// connection opened with the given dsn identifier, which rolls back its
+// transaction. It is nil if the close succeeded, or if no such connection
+// was closed yet. Suites can use it to assert a clean teardown, since
+// database/sql.DB.Close may not report the error.
+func (d *TxDriver) LastCloseError(dsn string) error {
+	d.Lock()
+	defer d.Unlock()
+	return d.closeErrs[dsn]
+}
+
+func (d *TxDriver) setCloseError(dsn string, err error) {
+	// d must be locked before call
+	if d.closeErrs == nil {
+		d.closeErrs = make(map[string]error)
+	}
+	d.closeErrs[dsn] = err
+}
+
+// SavePointDepth returns the number of nested transactions currently open
+// on the txdb connection behind db.
+func SavePointDepth(db *sql.DB) (depth int, err error) {
+	err = withConn(db, func(c *conn) error {
+		depth = len(c.stack)
+		return nil
+	})
+	return
+}
+
+// InNestedTx reports whether a nested transaction is currently open on the
+// txdb connection behind db.
+func InNestedTx(db *sql.DB) (bool, error) {
+	depth, err := SavePointDepth(db)
+	return depth > 0, err
+}
+
+// TxStartTime returns the time when the root transaction of the txdb
+// connection behind db has begun. It is zero if no statement was
+// executed yet.
+func TxStartTime(db *sql.DB) (start time.Time, err error) {
+	err = withConn(db, func(c *conn) error {
+		start = c.txStart
+		return nil
+	})
+	return
+}
+
+// withConn unwraps the txdb connection behind db and calls f with it locked.
+func withConn(db *sql.DB, f func(c *conn) error) error {
+	sc, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	return sc.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return fmt.Errorf("txdb: %T is not a txdb connection", driverConn)
+		}
+		c.Lock()
+		defer c.Unlock()
+		return f(c)
+	})
+}
@@ -0,0 +1,66 @@
+package txdb
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// StmtInfo describes a statement executed on a txdb connection, see
+// WithStmtHooks.
+type StmtInfo struct {
+	// DSN is the identifier of the connection.
+	DSN string
+	// Op is "Exec", "Query" or "Prepare".
+	Op    string
+	Query string
+	Args  []interface{}
+	// Duration and Err are the outcome, set only for the After hook.
+	Duration time.Duration
+	Err      error
+}
+
+// StmtHooks are called before and after every Exec, Query and Prepare, of
+// a connection or a prepared statement. Either of them may be nil.
+type StmtHooks struct {
+	Before func(StmtInfo)
+	After  func(StmtInfo)
+}
+
+// WithStmtHooks sets hooks called around every statement, for logging,
+// assertions or tracing, without wrapping the driver again. The hooks are
+// called without the connection locked, possibly concurrently.
+func WithStmtHooks(hooks StmtHooks) Option {
+	return func(cfg *Config) error {
+		cfg.stmtHooks = hooks
+		return nil
+	}
+}
+
+// stmtHook calls the Before hook and returns a function starting the
+// timer of the statement, to be called once the connection is locked, and
+// a function calling the After hook with the outcome of the statement.
+// It also accounts the statement latency, see TxDriver.Latency, unless
+// the statement failed before the connection was locked.
+func (c *conn) stmtHook(op, query string, args []driver.NamedValue) (func(), func(error)) {
+	var start time.Time
+	begin := func() { start = time.Now() }
+	elapsed := func() time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return c.timeStatement(start)
+	}
+	if c.stmtHooks.Before == nil && c.stmtHooks.After == nil {
+		return begin, func(error) { elapsed() }
+	}
+	info := StmtInfo{DSN: c.dsn, Op: op, Query: query, Args: mapNamedArgs(args)}
+	if c.stmtHooks.Before != nil {
+		c.stmtHooks.Before(info)
+	}
+	return begin, func(err error) {
+		info.Duration, info.Err = elapsed(), err
+		if c.stmtHooks.After != nil {
+			c.stmtHooks.After(info)
+		}
+	}
+}
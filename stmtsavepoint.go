@@ -0,0 +1,81 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// WithStatementSavePoints wraps every statement executed within the
+// transaction in its own savepoint, which is rolled back if the statement
+// fails. With postgres, a failed statement so does not abort the whole
+// transaction, which stops cascades of "current transaction is aborted"
+// errors. It costs two extra round trips per statement. Streamed queries
+// and prepared statements are not wrapped.
+//
+// name, if not nil, names the savepoint after the statement, e.g. after
+// its fingerprint, so server logs show meaningful savepoint names. The
+// name is turned into an identifier and made unique by txdb.
+func WithStatementSavePoints(name func(query string) string) Option {
+	return func(cfg *Config) error {
+		cfg.stmtSavePoints = true
+		cfg.stmtSavePointName = name
+		return nil
+	}
+}
+
+// statementSavePoint creates the savepoint of a statement, see
+// WithStatementSavePoints. The returned function must be called with the
+// statement error, it rolls back or releases the savepoint accordingly.
+func (c *conn) statementSavePoint(ctx context.Context, tx *sql.Tx, query string) (func(error) error, error) {
+	// c must be locked before call
+	if !c.stmtSavePoints || c.SavePoint == nil || isStreaming(ctx) {
+		return func(err error) error { return err }, nil
+	}
+
+	c.stmtSaves++
+	name := "txdb_stmt"
+	if c.stmtSavePointName != nil {
+		name = identifier(c.stmtSavePointName(query))
+	}
+	id := fmt.Sprintf("%s_%d", name, c.stmtSaves)
+	if err := c.execSavePoint(ctx, tx, c.SavePoint.Create(id)); err != nil {
+		return nil, err
+	}
+
+	return func(err error) error {
+		ctx := c.base() // ctx may be canceled by now
+		if err != nil {
+			if rerr := c.execSavePoint(ctx, tx, c.SavePoint.Rollback(id)); rerr != nil {
+				return fmt.Errorf("txdb: failed to rollback statement savepoint: %v, after: %w", rerr, err)
+			}
+		}
+		if rerr := c.execSavePoint(ctx, tx, c.SavePoint.Release(id)); rerr != nil && err == nil {
+			return rerr
+		}
+		return err
+	}, nil
+}
+
+// identifier turns name into a savepoint identifier, not longer than 48
+// bytes, leaving room for the unique suffix within common limits.
+func identifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		if b.Len() >= 48 {
+			break
+		}
+	}
+	id := b.String()
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		id = "s_" + id
+	}
+	return id
+}
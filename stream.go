@@ -0,0 +1,83 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+)
+
+// streamRows reads rows directly from the underlying result,
+// see WithStreaming.
+type streamRows struct {
+	conn     *conn
+	rs       *sql.Rows
+	cols     []string
+	colTypes []*sql.ColumnType
+	cancel   context.CancelFunc // releases the statement context
+	guard    rowsGuard
+}
+
+func newStreamRows(c *conn, rs *sql.Rows, cancel context.CancelFunc) (driver.Rows, error) {
+	cols, err := rs.Columns()
+	if err != nil {
+		rs.Close()
+		cancel()
+		return nil, err
+	}
+	colTypes, err := rs.ColumnTypes()
+	if err != nil {
+		rs.Close()
+		cancel()
+		return nil, err
+	}
+	return &streamRows{conn: c, rs: rs, cols: cols, colTypes: colTypes, cancel: cancel, guard: newRowsGuard(c)}, nil
+}
+
+func (r *streamRows) Columns() []string {
+	return r.cols
+}
+
+func (r *streamRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.colTypes[index].DatabaseTypeName()
+}
+
+func (r *streamRows) ColumnTypeScanType(index int) reflect.Type {
+	return r.colTypes[index].ScanType()
+}
+
+func (r *streamRows) Next(dest []driver.Value) error {
+	if err := r.guard.check(false); err != nil {
+		return err
+	}
+	r.conn.touch()
+	if !r.rs.Next() {
+		if err := r.rs.Err(); err != nil {
+			return err
+		}
+		if r.rs.NextResultSet() {
+			if err := r.conn.unsupported("only the first result set is available when streaming"); err != nil {
+				return err
+			}
+		}
+		return io.EOF
+	}
+
+	values := make([]interface{}, len(dest))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	if err := r.rs.Scan(values...); err != nil {
+		return err
+	}
+	for i, v := range values {
+		dest[i] = *(v.(*interface{}))
+	}
+	return nil
+}
+
+func (r *streamRows) Close() error {
+	defer r.cancel()
+	return r.rs.Close()
+}
@@ -0,0 +1,28 @@
+package txdb
+
+import (
+	"context"
+)
+
+// WithStrictContext makes connections honor the context of each call
+// strictly. The context only cancels the statement it was passed to, it
+// never cancels the root transaction, and statements issued with an
+// already done context fail immediately with the context error, instead
+// of a confusing error about the transaction state. Note, whether the
+// transaction survives a canceled statement depends on the database,
+// e.g. postgres aborts the transaction on any failed statement.
+func WithStrictContext() Option {
+	return func(cfg *Config) error {
+		cfg.strictContext = true
+		return nil
+	}
+}
+
+// checkContext fails with the context error in strict mode, see
+// WithStrictContext.
+func (c *conn) checkContext(ctx context.Context) error {
+	if !c.strictContext {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,55 @@
+package txdb
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// dsnIssue is a known problematic combination of a driver and its DSN.
+type dsnIssue struct {
+	driver string
+	// affects reports whether the issue applies to the dsn
+	affects func(dsn string) bool
+	msg     string
+}
+
+var dsnIssues = []dsnIssue{
+	{
+		driver:  "mysql",
+		affects: func(dsn string) bool { return dsnParam(dsn, "multiStatements") != "true" },
+		msg:     "mysql DSN lacks the multiStatements=true parameter, so queries with multiple statements or result sets fail and ExecBatch executes statements one by one",
+	},
+}
+
+// checkSupport reports every known issue of the driver and DSN combination
+// at registration, as an error with the Strict policy, see
+// WithUnsupportedPolicy, and as a warning with the standard logger
+// otherwise, since no connection logger applies yet.
+func (cfg *Config) checkSupport(drv, dsn string) error {
+	for _, issue := range dsnIssues {
+		if issue.driver != drv || !issue.affects(dsn) {
+			continue
+		}
+		if cfg.Policy == Strict {
+			return withKind(fmt.Errorf("txdb: %s, see WithUnsupportedPolicy", issue.msg), ErrUnsupported)
+		}
+		log.Printf("txdb: warning: %s", issue.msg)
+	}
+	return nil
+}
+
+// dsnParam returns the value of a query parameter of a DSN in the URL or
+// mysql format, like user@tcp(host)/db?param=value.
+func dsnParam(dsn, name string) string {
+	i := strings.LastIndex(dsn, "?")
+	if i < 0 {
+		return ""
+	}
+	params, err := url.ParseQuery(dsn[i+1:])
+	if err != nil {
+		return ""
+	}
+	return params.Get(name)
+}
@@ -0,0 +1,21 @@
+package txdb
+
+import "database/sql"
+
+// Suspend parks the transaction of the txdb connection behind db and calls
+// f with the real database, so that f runs on fresh connections outside of
+// the transaction. For example, to verify that data written in the test is
+// not visible to others, or to inspect the server state.
+//
+// Statements issued on db meanwhile wait until f returns and the
+// transaction is resumed. Note, f must not wait for rows locked by the
+// transaction, it would deadlock.
+func Suspend(db *sql.DB, f func(real *sql.DB) error) error {
+	return withConn(db, func(c *conn) error {
+		real, err := c.realDB()
+		if err != nil {
+			return err
+		}
+		return f(real)
+	})
+}
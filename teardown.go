@@ -0,0 +1,44 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// WithTeardown executes the given statements right before the root
+// transaction is rolled back, when the last connection is closed. For
+// example, deferred constraints can be validated with:
+//
+//	txdb.WithTeardown("SET CONSTRAINTS ALL IMMEDIATE")
+//
+// The transaction is rolled back regardless, a failure is returned by
+// Close.
+func WithTeardown(statements ...string) Option {
+	return WithTeardownFunc(func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return fmt.Errorf("%q: %w", statement, err)
+			}
+		}
+		return nil
+	})
+}
+
+// WithTeardownFunc calls f with the live root transaction right before it
+// is rolled back, like WithTeardown, e.g. to capture row counts or release
+// advisory locks.
+func WithTeardownFunc(f func(tx *sql.Tx) error) Option {
+	return func(cfg *Config) error {
+		cfg.teardowns = append(cfg.teardowns, f)
+		return nil
+	}
+}
+
+func (c *conn) teardown() error {
+	for _, f := range c.teardowns {
+		if err := f(c.tx); err != nil {
+			return fmt.Errorf("txdb: teardown of %q failed: %w", c.dsn, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package txdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// WithTempDir sets the directory the temporary directory of a connection
+// is created in, see TempDir, which is os.TempDir() by default. It must
+// be reachable by the database server, for SQL like COPY ... TO or
+// SELECT ... INTO OUTFILE to write into it, e.g. a volume mounted into a
+// database container.
+func WithTempDir(parent string) Option {
+	return func(cfg *Config) error {
+		cfg.tempParent = parent
+		return nil
+	}
+}
+
+// WithCleanupFunc calls f every time the root transaction is rolled back,
+// right after the rollback, so artifacts which SQL created outside of the
+// database during a test, like exported files, are removed along with
+// it. A failure is returned by the final Close.
+func WithCleanupFunc(f func() error) Option {
+	return func(cfg *Config) error {
+		cfg.cleanups = append(cfg.cleanups, f)
+		return nil
+	}
+}
+
+// TempDir returns the temporary directory of the txdb connection behind
+// db, creating it on the first call. It is removed with all its contents
+// every time the root transaction is rolled back, a later call creates a
+// new one.
+func TempDir(db *sql.DB) (dir string, err error) {
+	err = withConn(db, func(c *conn) error {
+		if c.tempDir == "" {
+			created, err := os.MkdirTemp(c.tempParent, "txdb-")
+			if err != nil {
+				return fmt.Errorf("txdb: failed to create temp dir of %q: %w", c.dsn, err)
+			}
+			c.tempDir = created
+		}
+		dir = c.tempDir
+		return nil
+	})
+	return dir, err
+}
+
+// cleanup runs the cleanup functions and removes the temporary directory,
+// once the root transaction was rolled back.
+func (c *conn) cleanup() error {
+	// c must be locked before call
+	var errs []error
+	for _, f := range c.cleanups {
+		if err := f(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.tempDir != "" {
+		if err := os.RemoveAll(c.tempDir); err != nil {
+			errs = append(errs, err)
+		}
+		c.tempDir = ""
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("txdb: cleanup of %q failed: %w", c.dsn, err)
+	}
+	return nil
+}
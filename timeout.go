@@ -0,0 +1,32 @@
+package txdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// statementContext bounds ctx by the timeout set with WithStatementTimeout,
+// unless ctx already has an earlier deadline.
+func (c *conn) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.StatementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= c.StatementTimeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(context.WithValue(ctx, timeoutKey, true), c.StatementTimeout)
+}
+
+// statementError describes err, if the statement was canceled by the
+// timeout set with WithStatementTimeout.
+func (c *conn) statementError(ctx context.Context, query string, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if v, _ := ctx.Value(timeoutKey).(bool); !v {
+		return err
+	}
+	return fmt.Errorf("txdb: statement %q did not finish within %s, see WithStatementTimeout: %w", query, c.StatementTimeout, err)
+}
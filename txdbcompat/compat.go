@@ -0,0 +1,265 @@
+// Package txdbcompat contains the core behavioral test suite of txdb,
+// parameterized by driver, so authors of other SQL drivers can verify
+// their driver works with txdb:
+//
+//	func TestTxdbCompat(t *testing.T) {
+//		txdbcompat.Run(t, txdbcompat.Config{
+//			Driver: "mydriver",
+//			DSN:    os.Getenv("MYDRIVER_DSN"),
+//		})
+//	}
+//
+// Projects supporting several databases, or configurations of them, can
+// run the suite against each of them as part of their CI with a Matrix:
+//
+//	func TestTxdbMatrix(t *testing.T) {
+//		txdbcompat.Matrix{
+//			{Name: "mysql", Driver: "mysql", DSN: os.Getenv("MYSQL_DSN"), MultiStatements: true},
+//			{Name: "postgres", Driver: "postgres", DSN: os.Getenv("PSQL_DSN"), Placeholder: txdbcompat.Dollar},
+//		}.Run(t)
+//	}
+//
+// The suite creates the txdbcompat_items table, if it does not exist, in
+// the database the DSN points to. Since txdb refuses to open databases
+// not ending with _test, the DSN must point to such database.
+package txdbcompat
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-txdb"
+)
+
+// Config describes the tested driver.
+type Config struct {
+	// Name names the configuration within a Matrix, defaults to Driver.
+	Name string
+	// Driver is the name the tested driver is registered with.
+	Driver string
+	// DSN is the data source name of a disposable database. The suite is
+	// skipped if it is empty, so it can be taken from an optional
+	// environment variable.
+	DSN string
+	// Placeholder returns the bind parameter for the n-th argument,
+	// starting from 1. Defaults to "?".
+	Placeholder func(n int) string
+	// MultiStatements is set if the driver, as configured by the DSN,
+	// supports multiple statements within a single query, which enables
+	// the multiple result sets test.
+	MultiStatements bool
+	// Capabilities, if set, are the capabilities the backend is expected
+	// to have, as detected by txdb.TxDriver.Capabilities. This guards
+	// against silently testing less, e.g. after a DSN option was lost.
+	Capabilities *txdb.Capabilities
+}
+
+// Dollar is a Config.Placeholder for drivers using $1, $2 and so on,
+// like postgres drivers do.
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (cfg Config) name() string {
+	if cfg.Name == "" {
+		return cfg.Driver
+	}
+	return cfg.Name
+}
+
+func (cfg Config) placeholder(n int) string {
+	if cfg.Placeholder == nil {
+		return "?"
+	}
+	return cfg.Placeholder(n)
+}
+
+// Matrix is a set of configurations the suite is run against, each as a
+// subtest named after the configuration.
+type Matrix []Config
+
+// Run runs the compatibility test suite with every configuration of m.
+func (m Matrix) Run(t *testing.T) {
+	t.Helper()
+	for _, cfg := range m {
+		cfg := cfg
+		t.Run(cfg.name(), func(t *testing.T) { Run(t, cfg) })
+	}
+}
+
+// Run runs the compatibility test suite with the driver described by cfg,
+// every test as a subtest of t.
+func Run(t *testing.T, cfg Config) {
+	t.Helper()
+	if cfg.DSN == "" {
+		t.Skipf("no DSN configured for %s", cfg.name())
+	}
+	if cfg.Capabilities != nil {
+		t.Run("Capabilities", func(t *testing.T) { testCapabilities(t, cfg) })
+	}
+	t.Run("NestedTransactions", func(t *testing.T) { testNestedTransactions(t, cfg) })
+	t.Run("Prepare", func(t *testing.T) { testPrepare(t, cfg) })
+	t.Run("ContextCancellation", func(t *testing.T) { testContextCancellation(t, cfg) })
+	t.Run("MultipleResultSets", func(t *testing.T) {
+		if !cfg.MultiStatements {
+			t.Skip("multiple statements are not supported by the driver")
+		}
+		testMultipleResultSets(t, cfg)
+	})
+}
+
+func open(t *testing.T, cfg Config) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(txdb.New(cfg.Driver, cfg.DSN, txdb.WithBootstrap(func(db *sql.DB) error {
+		_, err := db.Exec("CREATE TABLE IF NOT EXISTS txdbcompat_items (id INTEGER PRIMARY KEY, name VARCHAR(64) NOT NULL)")
+		return err
+	})))
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close the database: %s", err)
+		}
+	})
+	return db
+}
+
+func testCapabilities(t *testing.T, cfg Config) {
+	caps, err := txdb.New(cfg.Driver, cfg.DSN).Driver().(*txdb.TxDriver).Capabilities()
+	if err != nil {
+		t.Fatalf("failed to probe capabilities: %s", err)
+	}
+	if caps != *cfg.Capabilities {
+		t.Fatalf("expected capabilities %+v, but got %+v", *cfg.Capabilities, caps)
+	}
+}
+
+func insertSQL(cfg Config) string {
+	return "INSERT INTO txdbcompat_items (id, name) VALUES (" + cfg.placeholder(1) + ", " + cfg.placeholder(2) + ")"
+}
+
+func count(t *testing.T, q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, expected int) {
+	t.Helper()
+	var n int
+	if err := q.QueryRow("SELECT COUNT(*) FROM txdbcompat_items").Scan(&n); err != nil {
+		t.Fatalf("failed to count items: %s", err)
+	}
+	if n != expected {
+		t.Fatalf("expected %d items, but got %d", expected, n)
+	}
+}
+
+func testNestedTransactions(t *testing.T, cfg Config) {
+	db := open(t, cfg)
+	if _, err := db.Exec(insertSQL(cfg), 1, "root"); err != nil {
+		t.Fatalf("failed to insert an item: %s", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %s", err)
+	}
+	if _, err := tx.Exec(insertSQL(cfg), 2, "rolled back"); err != nil {
+		t.Fatalf("failed to insert an item: %s", err)
+	}
+	count(t, tx, 2)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to rollback transaction: %s", err)
+	}
+	count(t, db, 1)
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %s", err)
+	}
+	if _, err := tx.Exec(insertSQL(cfg), 2, "committed"); err != nil {
+		t.Fatalf("failed to insert an item: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %s", err)
+	}
+	count(t, db, 2)
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("failed to close the database: %s", err)
+	}
+	count(t, open(t, cfg), 0)
+}
+
+func testPrepare(t *testing.T, cfg Config) {
+	db := open(t, cfg)
+	stmt, err := db.Prepare(insertSQL(cfg))
+	if err != nil {
+		t.Fatalf("failed to prepare statement: %s", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err := stmt.Exec(i, "prepared"); err != nil {
+			t.Fatalf("failed to execute prepared statement: %s", err)
+		}
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatalf("failed to close prepared statement: %s", err)
+	}
+	count(t, db, 3)
+
+	if _, err := db.Prepare("SELECT * FROM txdbcompat_missing"); err == nil {
+		t.Fatal("expected an error when preparing an invalid statement")
+	}
+}
+
+func testContextCancellation(t *testing.T, cfg Config) {
+	db := open(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := db.ExecContext(ctx, insertSQL(cfg), 1, "first"); err != nil {
+		t.Fatalf("failed to insert an item: %s", err)
+	}
+	cancel() // must not affect the transaction, since the statement is done
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := db.ExecContext(ctx, insertSQL(cfg), 2, "second"); err != nil {
+		t.Fatalf("failed to insert an item after the previous context was canceled: %s", err)
+	}
+	count(t, db, 2)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.QueryContext(ctx, "SELECT id FROM txdbcompat_items"); err == nil {
+		t.Fatal("expected an error when querying with a canceled context")
+	}
+}
+
+func testMultipleResultSets(t *testing.T, cfg Config) {
+	db := open(t, cfg)
+	if _, err := db.Exec(insertSQL(cfg), 1, "multi"); err != nil {
+		t.Fatalf("failed to insert an item: %s", err)
+	}
+
+	rows, err := db.Query("SELECT name FROM txdbcompat_items; SELECT COUNT(*) FROM txdbcompat_items")
+	if err != nil {
+		t.Fatalf("failed to query items: %s", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to scan a name: %s", err)
+		}
+		names = append(names, name)
+	}
+	if !rows.NextResultSet() || !rows.Next() {
+		t.Fatal("expected the second result set")
+	}
+	var n int
+	if err := rows.Scan(&n); err != nil {
+		t.Fatalf("failed to scan count: %s", err)
+	}
+	if n != len(names) || n != 1 {
+		t.Fatalf("expected a single item in both result sets, but got %d and %d", len(names), n)
+	}
+}
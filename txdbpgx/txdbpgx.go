@@ -0,0 +1,185 @@
+// Package txdbpgx exposes the transaction of a txdb connection, opened on
+// the pgx stdlib driver, through the pgx.Tx interface, so code written
+// directly against pgx, instead of database/sql, can be tested with txdb:
+//
+//	txdb.Register("txdb", "pgx", "postgres://localhost/app_test")
+//
+//	func TestSaveUser(t *testing.T) {
+//		db, _ := sql.Open("txdb", t.Name())
+//		defer db.Close()
+//		sc, _ := db.Conn(ctx)
+//		defer sc.Close()
+//
+//		err := txdbpgx.Run(ctx, sc, func(tx pgx.Tx) error {
+//			return repo.SaveUser(ctx, tx, user)
+//		})
+//	}
+//
+// It is a module of its own, so txdb does not depend on pgx.
+package txdbpgx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DATA-DOG/go-txdb"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Run calls f with a pgx.Tx running within the transaction of the txdb
+// connection behind sc, see txdb.RawConn, which must be opened on the pgx
+// stdlib driver. The pgx.Tx is a savepoint, its Commit releases it, so the
+// changes stay visible on sc, until the txdb transaction is rolled back,
+// and its Rollback rolls back to it. Changes are rolled back as well, if f
+// fails or does not end the pgx.Tx. Begin nests further savepoints.
+//
+// The txdb connection is locked while f runs, so f must not use sc, and
+// the pgx.Tx must not be used after f returns. LargeObjects is not
+// supported, see tx.LargeObjects.
+func Run(ctx context.Context, sc *sql.Conn, f func(tx pgx.Tx) error) error {
+	return txdb.RawConn(sc, func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("txdb: %T is not a pgx stdlib connection", driverConn)
+		}
+		t, err := begin(ctx, c.Conn(), new(int))
+		if err != nil {
+			return err
+		}
+		if err := f(t); err != nil {
+			t.Rollback(ctx)
+			return err
+		}
+		if err := t.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			return err
+		}
+		return nil
+	})
+}
+
+// tx is a pgx.Tx on a savepoint of the txdb transaction.
+type tx struct {
+	conn   *pgx.Conn
+	seq    *int // savepoints begun by the Run, shared by all its tx
+	id     int
+	closed bool
+}
+
+var _ pgx.Tx = (*tx)(nil)
+
+func begin(ctx context.Context, conn *pgx.Conn, seq *int) (*tx, error) {
+	*seq++
+	t := &tx{conn: conn, seq: seq, id: *seq}
+	if _, err := conn.Exec(ctx, "SAVEPOINT "+t.savePoint()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// savePoint names the savepoint of t uniquely on the connection, so
+// sibling nested transactions do not share it.
+func (t *tx) savePoint() string {
+	return fmt.Sprintf("txdbpgx_%d", t.id)
+}
+
+func (t *tx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+	return begin(ctx, t.conn, t.seq)
+}
+
+func (t *tx) Commit(ctx context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	_, err := t.conn.Exec(ctx, "RELEASE SAVEPOINT "+t.savePoint())
+	t.closed = true
+	return err
+}
+
+func (t *tx) Rollback(ctx context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	if _, err := t.conn.Exec(ctx, "ROLLBACK TO SAVEPOINT "+t.savePoint()); err != nil {
+		return err
+	}
+	_, err := t.conn.Exec(ctx, "RELEASE SAVEPOINT "+t.savePoint())
+	return err
+}
+
+func (t *tx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if t.closed {
+		return 0, pgx.ErrTxClosed
+	}
+	return t.conn.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (t *tx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if t.closed {
+		return errBatchResults{pgx.ErrTxClosed}
+	}
+	return t.conn.SendBatch(ctx, b)
+}
+
+// LargeObjects is not supported, since pgx does not allow to build them
+// for a pgx.Tx of another package. The returned zero value panics when
+// any of its methods is called.
+func (t *tx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *tx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+	return t.conn.Prepare(ctx, name, sql)
+}
+
+func (t *tx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	if t.closed {
+		return pgconn.CommandTag{}, pgx.ErrTxClosed
+	}
+	return t.conn.Exec(ctx, sql, arguments...)
+}
+
+func (t *tx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+	return t.conn.Query(ctx, sql, args...)
+}
+
+func (t *tx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if t.closed {
+		return errRow{pgx.ErrTxClosed}
+	}
+	return t.conn.QueryRow(ctx, sql, args...)
+}
+
+func (t *tx) Conn() *pgx.Conn {
+	return t.conn
+}
+
+// errRow fails scanning with err.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...interface{}) error { return r.err }
+
+// errBatchResults fails every result of a batch with err.
+type errBatchResults struct {
+	err error
+}
+
+func (r errBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, r.err }
+func (r errBatchResults) Query() (pgx.Rows, error)         { return nil, r.err }
+func (r errBatchResults) QueryRow() pgx.Row                { return errRow{r.err} }
+func (r errBatchResults) Close() error                     { return r.err }
@@ -0,0 +1,116 @@
+package txdbpgx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-txdb"
+	"github.com/DATA-DOG/go-txdb/memory"
+	"github.com/DATA-DOG/go-txdb/txdbpgx"
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// conn returns a connection of a txdb database on the pgx stdlib driver,
+// with an empty users table, or calls t.Skip if PSQL_DSN is unset.
+func conn(t *testing.T) *sql.Conn {
+	t.Helper()
+	dsn := os.Getenv("PSQL_DSN")
+	if dsn == "" || strings.ToLower(dsn) == "auto" {
+		t.Skip("PSQL_DSN not set, skipping tests for pgx")
+	}
+	db := sql.OpenDB(txdb.New("pgx", dsn))
+	t.Cleanup(func() { db.Close() })
+	sc, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open a connection: %s", err)
+	}
+	t.Cleanup(func() { sc.Close() })
+	if _, err := sc.ExecContext(context.Background(), "CREATE TEMPORARY TABLE users (name TEXT)"); err != nil {
+		t.Fatalf("failed to create a table: %s", err)
+	}
+	return sc
+}
+
+func count(t *testing.T, ctx context.Context, tx pgx.Tx) (n int) {
+	t.Helper()
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("failed to count users: %s", err)
+	}
+	return n
+}
+
+func TestShouldRequirePgxConnection(t *testing.T) {
+	memory.Register()
+	db := sql.OpenDB(txdb.New(memory.DriverName, "txdbpgx"))
+	defer db.Close()
+	sc, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open a connection: %s", err)
+	}
+	defer sc.Close()
+
+	err = txdbpgx.Run(context.Background(), sc, func(pgx.Tx) error {
+		t.Fatal("expected f not to be called")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "is not a pgx stdlib connection") {
+		t.Fatalf("expected a connection type error, but got: %v", err)
+	}
+}
+
+func TestShouldRollBackFailedRun(t *testing.T) {
+	ctx := context.Background()
+	sc := conn(t)
+	failed := errors.New("failed")
+
+	err := txdbpgx.Run(ctx, sc, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "INSERT INTO users (name) VALUES ('txdb')"); err != nil {
+			t.Fatalf("failed to insert an user: %s", err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of f, but got: %v", err)
+	}
+	var n int
+	if err := sc.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&n); err != nil || n != 0 {
+		t.Fatalf("expected the insert to be rolled back, but got %d users: %v", n, err)
+	}
+}
+
+func TestShouldNestSiblingTransactions(t *testing.T) {
+	ctx := context.Background()
+	sc := conn(t)
+
+	err := txdbpgx.Run(ctx, sc, func(tx pgx.Tx) error {
+		first, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := first.Exec(ctx, "INSERT INTO users (name) VALUES ('first')"); err != nil {
+			return err
+		}
+		second, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := second.Exec(ctx, "INSERT INTO users (name) VALUES ('second')"); err != nil {
+			return err
+		}
+		if err := first.Rollback(ctx); err != nil {
+			return err
+		}
+		if n := count(t, ctx, tx); n != 0 {
+			t.Fatalf("expected the rollback of the first to undo both inserts, but got %d users", n)
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		t.Fatalf("failed to run: %s", err)
+	}
+}
@@ -0,0 +1,65 @@
+package txdb
+
+import (
+	"time"
+)
+
+// TxInfo describes a boundary of a nested transaction, see WithTxHooks.
+type TxInfo struct {
+	// DSN is the identifier of the connection.
+	DSN string
+	// SavePoint is the id of the savepoint backing the transaction.
+	SavePoint string
+	// Depth is the number of nested transactions open after the boundary.
+	Depth int
+	// Duration is how long the transaction was open, zero on Begin.
+	Duration time.Duration
+	// Err is the error of Commit or Rollback, if any.
+	Err error
+}
+
+// TxHooks are called on the boundaries of nested transactions, which are
+// backed by savepoints, see WithTxHooks. Any of them may be nil.
+type TxHooks struct {
+	Begin    func(TxInfo)
+	Commit   func(TxInfo)
+	Rollback func(TxInfo)
+}
+
+// WithTxHooks sets hooks called when nested transactions begin, commit or
+// roll back, so tests can assert transaction boundaries of the code under
+// test and measure how long its transactions stay open. Transactions
+// without a savepoint, see SkipSavePoint, are not reported. Hooks are
+// called with the connection locked, so they must not use the database.
+func WithTxHooks(hooks TxHooks) Option {
+	return func(cfg *Config) error {
+		cfg.txHooks = hooks
+		return nil
+	}
+}
+
+func (c *conn) txBegun(id string) {
+	// c must be locked before call
+	if c.txHooks.Begin == nil && c.txHooks.Commit == nil && c.txHooks.Rollback == nil {
+		return
+	}
+	if c.txBegan == nil {
+		c.txBegan = make(map[string]time.Time)
+	}
+	c.txBegan[id] = time.Now()
+	if c.txHooks.Begin != nil {
+		c.txHooks.Begin(TxInfo{DSN: c.dsn, SavePoint: id, Depth: len(c.stack)})
+	}
+}
+
+func (c *conn) txEnded(hook func(TxInfo), id string, err error) {
+	// c must be locked before call
+	began, ok := c.txBegan[id]
+	if !ok {
+		return
+	}
+	delete(c.txBegan, id)
+	if hook != nil {
+		hook(TxInfo{DSN: c.dsn, SavePoint: id, Depth: len(c.stack), Duration: time.Since(began), Err: err})
+	}
+}
@@ -0,0 +1,22 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+var uniqueDSNs uint64
+
+// UniqueDSN returns a dsn identifier which is unique within the process,
+// so the connection opened with it gets its own transaction.
+func UniqueDSN() string {
+	return fmt.Sprintf("txdb_unique_%d", atomic.AddUint64(&uniqueDSNs, 1))
+}
+
+// OpenUnique opens the txdb driver registered under driverName with a
+// unique dsn identifier, see UniqueDSN, so every call gets an isolated
+// transaction.
+func OpenUnique(driverName string) (*sql.DB, error) {
+	return sql.Open(driverName, UniqueDSN())
+}
@@ -0,0 +1,70 @@
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// validate checks the options, the driver and the DSN at registration, so
+// a bad one fails every Open with a descriptive error, rather than some
+// unrelated statement later. Only the lookup of a driver not registered
+// yet, for example by a later init, is left to the first Open. The real
+// database is pinged, if requested with WithPingOnRegister, without
+// keeping it open.
+func (d *TxDriver) validate() error {
+	var cfg Config
+	for _, opt := range d.options {
+		if err := opt(&cfg); err != nil {
+			return err
+		}
+	}
+	if d.pool == nil && d.connector == nil {
+		if err := checkDSNParams(d.drv, d.dsn); err != nil {
+			return err
+		}
+		if err := cfg.checkSupport(d.drv, d.dsn); err != nil {
+			return err
+		}
+		if !cfg.pingOnRegister && !slices.Contains(sql.Drivers(), d.drv) {
+			return nil
+		}
+	}
+
+	// drivers implementing driver.DriverContext parse the dsn here
+	db, err := d.openReal()
+	if err != nil {
+		return err
+	}
+	defer d.closeReal(db)
+	if !cfg.pingOnRegister {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// invalid describes err of a check of the registration.
+func (d *TxDriver) invalid(err error) error {
+	switch {
+	case d.pool != nil:
+		return fmt.Errorf("txdb: invalid registration of %s pool: %w", d.drv, err)
+	case d.connector != nil:
+		return fmt.Errorf("txdb: invalid registration of %s connector: %w", d.drv, err)
+	}
+	return fmt.Errorf("txdb: invalid registration of %s driver: %w", d.drv, err)
+}
+
+// checkOptions applies the unsupported policy to options the driver can
+// not provide, once all options were applied to c.
+func (c *conn) checkOptions() error {
+	if c.checkArgTypes && c.drv.drv != "postgres" && c.drv.drv != "pgx" {
+		c.checkArgTypes = false
+		return c.unsupported("argument type checking is not supported for %s driver", c.drv.drv)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package txdb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ServerVersion is the version of the database server, probed once it is
+// first needed.
+type ServerVersion struct {
+	// Product is "mysql", "mariadb", "postgres" or "sqlite".
+	Product string
+	Major   int
+	Minor   int
+	Patch   int
+	// Raw is the version as reported by the server.
+	Raw string
+}
+
+// AtLeast reports whether the version is major.minor or newer.
+func (v ServerVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+func (v ServerVersion) String() string {
+	return fmt.Sprintf("%s %d.%d.%d", v.Product, v.Major, v.Minor, v.Patch)
+}
+
+// ServerVersion returns the version of the database server, so tests can
+// branch on it. It is probed on the first call once the real database is
+// open, or when a server specific behavior depends on it, so connections
+// do not pay for it otherwise. It reports false if the database was not
+// opened yet, or the version could not be detected.
+func (d *TxDriver) ServerVersion() (ServerVersion, bool) {
+	d.Lock()
+	defer d.Unlock()
+	v := d.serverVersion()
+	return v, v.Product != ""
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// serverVersion returns the version of the database server, probing it
+// once, unless the driver is not one parseServerVersion understands. A
+// failed probe is not retried, since the server may not report it.
+func (d *TxDriver) serverVersion() ServerVersion {
+	// d must be locked before call
+	query := versionQuery(d.drv)
+	if query == "" || d.versionProbed || d.db == nil {
+		return d.version
+	}
+	d.versionProbed = true
+	var raw string
+	if err := d.db.QueryRow(query).Scan(&raw); err != nil {
+		return d.version
+	}
+	d.version, _ = parseServerVersion(d.drv, raw)
+	return d.version
+}
+
+// versionQuery returns the query reporting the server version for the
+// drivers parseServerVersion understands, or an empty string.
+func versionQuery(drv string) string {
+	switch {
+	case drv == "mysql", drv == "postgres", drv == "pgx":
+		return "SELECT VERSION()"
+	case strings.HasPrefix(drv, "sqlite"):
+		return "SELECT sqlite_version()"
+	}
+	return ""
+}
+
+// parseServerVersion parses the version reported by the server.
+func parseServerVersion(drv, raw string) (ServerVersion, bool) {
+	v := ServerVersion{Raw: raw}
+	text := raw
+	switch {
+	case strings.HasPrefix(drv, "sqlite"):
+		v.Product = "sqlite"
+	case strings.HasPrefix(raw, "PostgreSQL "):
+		v.Product, text = "postgres", strings.TrimPrefix(raw, "PostgreSQL ")
+	case strings.Contains(raw, "MariaDB"):
+		// replication compatible servers prefix the real version
+		v.Product, text = "mariadb", strings.TrimPrefix(raw, "5.5.5-")
+	case drv == "mysql":
+		v.Product = "mysql"
+	default:
+		return ServerVersion{}, false
+	}
+	m := versionPattern.FindStringSubmatch(text)
+	if m == nil || versionPattern.FindStringIndex(text)[0] != 0 {
+		return ServerVersion{}, false
+	}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, true
+}